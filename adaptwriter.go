@@ -0,0 +1,58 @@
+package iochain
+
+import "io"
+
+// LegacyResetter is a writer with an error-less Reset, such as *gzip.Writer,
+// *zlib.Writer, *flate.Writer or *bufio.Writer.
+type LegacyResetter interface {
+	io.Writer
+	Reset(w io.Writer)
+}
+
+// adaptedWriter adapts a LegacyResetter to ResettableWriter.
+type adaptedWriter struct {
+	w LegacyResetter
+}
+
+// AdaptWriter returns a ResettableWriter for a writer whose Reset does not return an
+// error, so it can be used with StackWriter.AddWriter. Flush and Close are forwarded
+// when the adapted writer implements them.
+func AdaptWriter(w LegacyResetter) ResettableWriter {
+	return &adaptedWriter{w: w}
+}
+
+func (a *adaptedWriter) Write(p []byte) (int, error) {
+	return a.w.Write(p)
+}
+
+// WriteString uses the adapted writer's io.StringWriter implementation when it has one.
+func (a *adaptedWriter) WriteString(s string) (int, error) {
+	if sw, ok := a.w.(io.StringWriter); ok {
+		return sw.WriteString(s)
+	}
+	return a.w.Write([]byte(s))
+}
+
+func (a *adaptedWriter) Reset(w io.Writer) error {
+	a.w.Reset(w)
+	return nil
+}
+
+func (a *adaptedWriter) Flush() error {
+	if flusher, ok := a.w.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (a *adaptedWriter) Close() error {
+	if closer, ok := a.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the adapted writer.
+func (a *adaptedWriter) Unwrap() io.Writer {
+	return a.w
+}
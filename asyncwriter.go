@@ -0,0 +1,181 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrQueueFull is returned by AsyncWriter.Write when FailWhenFull is set and the queue has no room.
+var ErrQueueFull = errors.New("async writer queue is full")
+
+// asyncOp is either a chunk of data or, if done is set, a flush barrier.
+type asyncOp struct {
+	p    []byte
+	done chan struct{}
+}
+
+// AsyncWriter queues writes and drains them to the underlying writer in a background
+// goroutine. Write blocks only while the queue is full, or fails with ErrQueueFull if
+// FailWhenFull is set.
+//
+// Flush is a barrier: it returns once every write enqueued before it has reached the
+// underlying writer, regardless of writes enqueued concurrently after it.
+// The first error from the underlying writer is returned by later calls.
+// Close must be called to stop the background goroutine.
+type AsyncWriter struct {
+	// FailWhenFull makes Write return ErrQueueFull instead of blocking when the queue
+	// is full. It must be set before the first Write.
+	FailWhenFull bool
+
+	mu     sync.Mutex // serializes enqueueing with Close and Reset
+	queue  chan asyncOp
+	exited chan struct{}
+	closed bool
+
+	tmu    sync.Mutex // guards target; held by the goroutine while writing
+	target io.Writer
+
+	emu sync.Mutex // guards err, so Write can check it while a write is in progress
+	err error
+}
+
+// NewAsyncWriter creates an AsyncWriter writing to w with room for queueSize pending writes.
+func NewAsyncWriter(w io.Writer, queueSize int) *AsyncWriter {
+	a := &AsyncWriter{
+		queue:  make(chan asyncOp, queueSize),
+		exited: make(chan struct{}),
+		target: w,
+	}
+	go a.run(a.queue, a.exited)
+	return a
+}
+
+// Write copies p into the queue. A nil error does not mean the data has been written;
+// failures are reported by subsequent calls.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if err := a.loadErr(); err != nil {
+		return 0, err
+	}
+	op := asyncOp{p: append([]byte(nil), p...)}
+	if !a.FailWhenFull {
+		a.queue <- op
+		return len(p), nil
+	}
+	select {
+	case a.queue <- op:
+		return len(p), nil
+	default:
+		return 0, ErrQueueFull
+	}
+}
+
+// Pending returns the number of queued writes and flush barriers not yet taken up by
+// the background goroutine.
+func (a *AsyncWriter) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.queue)
+}
+
+// Flush waits until all writes enqueued before the call have been written.
+// The underlying writer is not flushed; StackWriter flushes each layer in turn.
+func (a *AsyncWriter) Flush() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return a.loadErr()
+	}
+	done := a.barrier()
+	a.mu.Unlock()
+
+	<-done
+	return a.loadErr()
+}
+
+// Reset waits for queued writes to reach the current writer and then switches to w.
+// Any recorded error is cleared. A closed AsyncWriter is restarted.
+func (a *AsyncWriter) Reset(w io.Writer) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		a.queue = make(chan asyncOp, cap(a.queue))
+		a.exited = make(chan struct{})
+		a.closed = false
+		go a.run(a.queue, a.exited)
+	} else {
+		<-a.barrier()
+	}
+
+	a.tmu.Lock()
+	a.target = w
+	a.tmu.Unlock()
+	a.storeErr(nil)
+	return nil
+}
+
+// Close drains the queue and stops the background goroutine.
+// The underlying writer is not closed.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return a.loadErr()
+	}
+	a.closed = true
+	close(a.queue)
+	exited := a.exited
+	a.mu.Unlock()
+
+	<-exited
+	return a.loadErr()
+}
+
+// barrier enqueues a flush barrier and returns the channel closed when it is reached.
+// The caller must hold a.mu.
+func (a *AsyncWriter) barrier() chan struct{} {
+	done := make(chan struct{})
+	a.queue <- asyncOp{done: done}
+	return done
+}
+
+func (a *AsyncWriter) loadErr() error {
+	a.emu.Lock()
+	defer a.emu.Unlock()
+	return a.err
+}
+
+func (a *AsyncWriter) storeErr(err error) {
+	a.emu.Lock()
+	defer a.emu.Unlock()
+	a.err = err
+}
+
+func (a *AsyncWriter) run(queue <-chan asyncOp, exited chan<- struct{}) {
+	defer close(exited)
+	for op := range queue {
+		if op.done != nil {
+			close(op.done)
+			continue
+		}
+
+		a.tmu.Lock()
+		if a.loadErr() == nil {
+			if a.target == nil {
+				a.storeErr(io.ErrClosedPipe)
+			} else if n, err := a.target.Write(op.p); err != nil {
+				a.storeErr(err)
+			} else if n < len(op.p) {
+				a.storeErr(io.ErrShortWrite)
+			}
+		}
+		a.tmu.Unlock()
+	}
+}
@@ -0,0 +1,93 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAsyncWriterFlushWaitsForEarlierWrites(t *testing.T) {
+	base := newBlockingWriter()
+	a := NewAsyncWriter(base, 4)
+	if _, err := a.Write([]byte("queued")); err != nil {
+		t.Fatal(err)
+	}
+	<-base.started
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- a.Flush() }()
+	select {
+	case <-flushed:
+		t.Fatal("Flush returned before the queued write reached the writer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(base.release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncWriterOrderAndReset(t *testing.T) {
+	var first, second bytes.Buffer
+	a := NewAsyncWriter(&first, 2)
+	for _, p := range []string{"a", "b", "c", "d"} {
+		a.Write([]byte(p))
+	}
+	if err := a.Reset(&second); err != nil {
+		t.Fatal(err)
+	}
+	a.Write([]byte("e"))
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "abcd" || second.String() != "e" {
+		t.Fatalf("first = %q, second = %q", first.String(), second.String())
+	}
+	if _, err := a.Write([]byte("f")); err == nil {
+		t.Fatal("Write after Close succeeded")
+	}
+}
+
+func TestAsyncWriterReportsWriteError(t *testing.T) {
+	errWrite := errors.New("disk full")
+	a := NewAsyncWriter(failWriter{errWrite}, 4)
+	a.Write([]byte("lost"))
+	if err := a.Flush(); err != errWrite {
+		t.Fatalf("Flush = %v, want %v", err, errWrite)
+	}
+	if _, err := a.Write([]byte("more")); err != errWrite {
+		t.Fatalf("Write after a failure = %v, want %v", err, errWrite)
+	}
+	if err := a.Close(); err != errWrite {
+		t.Fatalf("Close = %v, want %v", err, errWrite)
+	}
+}
+
+func TestAsyncWriterFailWhenFull(t *testing.T) {
+	base := newBlockingWriter()
+	a := NewAsyncWriter(base, 1)
+	a.FailWhenFull = true
+	a.Write([]byte("taken"))
+	<-base.started
+	if _, err := a.Write([]byte("queued")); err != nil {
+		t.Fatal(err)
+	}
+	if a.Pending() != 1 {
+		t.Fatalf("Pending = %d, want 1", a.Pending())
+	}
+	if _, err := a.Write([]byte("dropped")); err != ErrQueueFull {
+		t.Fatalf("Write to a full queue = %v, want %v", err, ErrQueueFull)
+	}
+	close(base.release)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if base.writes != 2 {
+		t.Fatalf("%d writes reached the writer, want 2", base.writes)
+	}
+}
@@ -0,0 +1,97 @@
+package iochain
+
+import "time"
+
+// autoFlush holds the auto-flush policy of a StackWriter.
+type autoFlush struct {
+	every     int64         // flush once this many bytes were written since the last flush
+	flushedAt int64         // bytes written at the last flush
+	stop      chan struct{} // closed to stop the timer goroutine, nil without interval
+	err       error         // failure of a timed flush, returned by the next write
+}
+
+// SetAutoFlush makes the stack flush itself once everyBytes have been written since the
+// last flush, and every interval from a background goroutine while there is unflushed
+// data. A zero value disables that trigger; both zero turns auto-flush off. A failed
+// timed flush is returned by the next write, which then writes nothing. The background
+// goroutine stops on Close.
+func (m *StackWriter) SetAutoFlush(everyBytes int64, interval time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	m.stopAutoFlush()
+	if everyBytes <= 0 && interval <= 0 {
+		return nil
+	}
+
+	af := &autoFlush{every: everyBytes, flushedAt: m.written.Load()}
+	if interval > 0 {
+		af.stop = make(chan struct{})
+		go m.runAutoFlush(af, interval)
+	}
+	m.autoFlush = af
+	return nil
+}
+
+// runAutoFlush flushes the stack every interval while there is unflushed data, until
+// af is stopped.
+func (m *StackWriter) runAutoFlush(af *autoFlush, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-af.stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		select {
+		case <-af.stop:
+			m.mu.Unlock()
+			return
+		default:
+		}
+		if m.written.Load() != af.flushedAt {
+			if err := m.flushLocked(); err != nil && af.err == nil {
+				af.err = err
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// stopAutoFlush turns auto-flush off. The lock must be held.
+func (m *StackWriter) stopAutoFlush() {
+	if m.autoFlush != nil && m.autoFlush.stop != nil {
+		close(m.autoFlush.stop)
+	}
+	m.autoFlush = nil
+}
+
+// beforeWrite reports a failed chain or timed flush and rotates the base if needed.
+// The lock must be held and the stack non-empty.
+func (m *StackWriter) beforeWrite() error {
+	if m.failed {
+		return ErrChainFailed
+	}
+	if af := m.autoFlush; af != nil && af.err != nil {
+		err := af.err
+		af.err = nil
+		return err
+	}
+	return m.maybeRotate()
+}
+
+// afterWrite flushes the stack if the auto-flush byte threshold has been reached. The
+// lock must be held and the stack non-empty.
+func (m *StackWriter) afterWrite() error {
+	if af := m.autoFlush; af != nil && af.every > 0 && m.written.Load()-af.flushedAt >= af.every {
+		return m.flushLocked()
+	}
+	return nil
+}
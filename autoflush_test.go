@@ -0,0 +1,79 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe for the background flushes of auto-flush.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAutoFlushEveryBytes(t *testing.T) {
+	out := &lockedBuffer{}
+	sw, err := NewStackWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetAutoFlush(10, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.Write([]byte("123456"))
+	if out.String() != "" {
+		t.Fatalf("flushed %q below the threshold", out.String())
+	}
+	sw.Write([]byte("789012"))
+	if out.String() != "123456789012" {
+		t.Fatalf("output = %q after crossing the threshold", out.String())
+	}
+}
+
+func TestAutoFlushInterval(t *testing.T) {
+	out := &lockedBuffer{}
+	sw, err := NewStackWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetAutoFlush(0, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.Write([]byte("timed"))
+	deadline := time.Now().Add(2 * time.Second)
+	for out.String() != "timed" {
+		if time.Now().After(deadline) {
+			t.Fatal("the timer did not flush the buffered write")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetAutoFlush(1, 0); err != ErrChainClosed {
+		t.Fatalf("SetAutoFlush after Close = %v, want %v", err, ErrChainClosed)
+	}
+}
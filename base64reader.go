@@ -0,0 +1,37 @@
+package iochain
+
+import (
+	"encoding/base64"
+	"io"
+)
+
+var _ ResettableReader = (*Base64Reader)(nil)
+
+// Base64Reader decodes base64 read from its source. Newlines in the input are ignored.
+type Base64Reader struct {
+	enc *base64.Encoding
+	r   io.Reader
+}
+
+// NewBase64Reader creates a Base64Reader using enc, or base64.StdEncoding if enc is nil.
+// The source is set with Reset, typically by MultiReader.AddReader.
+func NewBase64Reader(enc *base64.Encoding) *Base64Reader {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return &Base64Reader{enc: enc}
+}
+
+// Read reads decoded data.
+func (b *Base64Reader) Read(p []byte) (int, error) {
+	if b.r == nil {
+		return 0, io.EOF
+	}
+	return b.r.Read(p)
+}
+
+// Reset starts decoding from src.
+func (b *Base64Reader) Reset(src io.Reader) error {
+	b.r = base64.NewDecoder(b.enc, src)
+	return nil
+}
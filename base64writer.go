@@ -0,0 +1,47 @@
+package iochain
+
+import (
+	"encoding/base64"
+	"io"
+)
+
+var _ ResettableWriter = (*Base64Writer)(nil)
+
+// Base64Writer base64-encodes everything written through it. Complete 3-byte groups
+// are encoded as they arrive; the final partial group, with its padding, is written
+// on Close, so Flush never emits padding in the middle of the stream.
+type Base64Writer struct {
+	enc *base64.Encoding
+	w   io.WriteCloser
+}
+
+// NewBase64Writer creates a Base64Writer using enc, or base64.StdEncoding if enc is nil.
+// The downstream writer is set with Reset, typically by StackWriter.AddWriter.
+func NewBase64Writer(enc *base64.Encoding) *Base64Writer {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return &Base64Writer{enc: enc}
+}
+
+// Write encodes p.
+func (b *Base64Writer) Write(p []byte) (int, error) {
+	if b.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return b.w.Write(p)
+}
+
+// Reset starts a new encoding to w. A pending partial group is discarded.
+func (b *Base64Writer) Reset(w io.Writer) error {
+	b.w = base64.NewEncoder(b.enc, w)
+	return nil
+}
+
+// Close writes the final partial group. The downstream writer is not closed.
+func (b *Base64Writer) Close() error {
+	if b.w == nil {
+		return nil
+	}
+	return b.w.Close()
+}
@@ -0,0 +1,68 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodingChainsRoundTrip(t *testing.T) {
+	for _, spec := range []string{"base64", "base64:encoding=rawurl", "hex", "hex|base64"} {
+		for size := range 6 {
+			data := strings.Repeat("\xfb", size)
+			var out bytes.Buffer
+			sw, err := BuildWriterChain(&out, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(sw, data); err != nil {
+				t.Fatal(err)
+			}
+			if err := sw.FlushAndClose(); err != nil {
+				t.Fatal(err)
+			}
+
+			mr, err := BuildReaderChain(&out, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(mr)
+			if err != nil || string(got) != data {
+				t.Fatalf("%s, %d bytes: read back %q, %v", spec, size, got, err)
+			}
+		}
+	}
+}
+
+func TestBase64WriterPadsOnlyOnClose(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewBase64Writer(nil)); err != nil {
+		t.Fatal(err)
+	}
+	sw.Write([]byte("abcd"))
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "YWJj" {
+		t.Fatalf("output after Flush = %q, want only the complete group", out.String())
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "YWJjZA==" {
+		t.Fatalf("output after Close = %q, want %q", out.String(), "YWJjZA==")
+	}
+}
+
+func TestHexReaderRejectsInvalidInput(t *testing.T) {
+	hr := NewHexReader()
+	hr.Reset(strings.NewReader("6869zz"))
+	if _, err := io.ReadAll(hr); err == nil {
+		t.Fatal("HexReader accepted non-hex input")
+	}
+}
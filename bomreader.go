@@ -0,0 +1,202 @@
+package iochain
+
+import (
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoding identifies the Unicode encoding announced by a byte-order mark.
+type TextEncoding int
+
+const (
+	// EncodingUnknown means no byte-order mark was found.
+	EncodingUnknown TextEncoding = iota
+	EncodingUTF8
+	EncodingUTF16BE
+	EncodingUTF16LE
+	EncodingUTF32BE
+	EncodingUTF32LE
+)
+
+// String returns the conventional name of the encoding.
+func (e TextEncoding) String() string {
+	switch e {
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF32BE:
+		return "UTF-32BE"
+	case EncodingUTF32LE:
+		return "UTF-32LE"
+	default:
+		return "unknown"
+	}
+}
+
+// BOMReader strips a leading byte-order mark from its source.
+// If Transcode is set, UTF-16 and UTF-32 input is converted to UTF-8.
+type BOMReader struct {
+	src       io.Reader
+	Transcode bool
+
+	enc      TextEncoding
+	detected bool
+	pending  []byte // bytes read while sniffing that belong to the stream
+	err      error  // error returned by src during sniffing or transcoding
+
+	raw  [4096]byte // undecoded input; the first carry bytes are left over from the previous read
+	nraw int
+	out  []byte // transcoded UTF-8 not yet returned
+}
+
+// NewBOMReader creates a BOMReader reading from r.
+func NewBOMReader(r io.Reader) *BOMReader {
+	return &BOMReader{src: r}
+}
+
+// DetectedEncoding returns the encoding announced by the byte-order mark.
+// It returns EncodingUnknown until the first Read, or when no mark was present.
+func (r *BOMReader) DetectedEncoding() TextEncoding {
+	return r.enc
+}
+
+// Reset discards any state and starts reading from src.
+func (r *BOMReader) Reset(src io.Reader) error {
+	transcode := r.Transcode
+	*r = BOMReader{src: src, Transcode: transcode}
+	return nil
+}
+
+// Read returns the stream without its byte-order mark.
+func (r *BOMReader) Read(p []byte) (int, error) {
+	if !r.detected {
+		r.detect()
+	}
+	if r.transcoding() {
+		return r.readTranscoded(p)
+	}
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.src.Read(p)
+}
+
+// detect peeks at the first bytes of the stream and consumes a byte-order mark if present.
+func (r *BOMReader) detect() {
+	r.detected = true
+
+	var head [4]byte
+	n, err := io.ReadFull(r.src, head[:])
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	r.err = err
+
+	b := head[:n]
+	skip := 0
+	switch {
+	case len(b) >= 4 && b[0] == 0x00 && b[1] == 0x00 && b[2] == 0xFE && b[3] == 0xFF:
+		r.enc, skip = EncodingUTF32BE, 4
+	case len(b) >= 4 && b[0] == 0xFF && b[1] == 0xFE && b[2] == 0x00 && b[3] == 0x00:
+		r.enc, skip = EncodingUTF32LE, 4
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		r.enc, skip = EncodingUTF8, 3
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		r.enc, skip = EncodingUTF16BE, 2
+	case len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE:
+		r.enc, skip = EncodingUTF16LE, 2
+	}
+
+	rest := b[skip:]
+	if r.transcoding() {
+		r.nraw = copy(r.raw[:], rest)
+		return
+	}
+	r.pending = append([]byte(nil), rest...)
+}
+
+func (r *BOMReader) transcoding() bool {
+	return r.Transcode && r.enc != EncodingUnknown && r.enc != EncodingUTF8
+}
+
+func (r *BOMReader) readTranscoded(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			r.decode(true)
+			if len(r.out) == 0 {
+				return 0, r.err
+			}
+			break
+		}
+		n, err := r.src.Read(r.raw[r.nraw:])
+		r.nraw += n
+		r.err = err
+		r.decode(false)
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// decode converts complete code units in r.raw to UTF-8 and keeps any trailing partial unit.
+// When final is set, a trailing partial unit is emitted as U+FFFD.
+func (r *BOMReader) decode(final bool) {
+	var order binary.ByteOrder = binary.BigEndian
+	if r.enc == EncodingUTF16LE || r.enc == EncodingUTF32LE {
+		order = binary.LittleEndian
+	}
+
+	b := r.raw[:r.nraw]
+	switch r.enc {
+	case EncodingUTF16BE, EncodingUTF16LE:
+		for len(b) >= 2 {
+			u := rune(order.Uint16(b))
+			switch {
+			case !utf16.IsSurrogate(u):
+				r.out = utf8.AppendRune(r.out, u)
+				b = b[2:]
+			case u < 0xDC00 && len(b) >= 4:
+				ru := utf16.DecodeRune(u, rune(order.Uint16(b[2:])))
+				if ru == utf8.RuneError {
+					r.out = utf8.AppendRune(r.out, utf8.RuneError)
+					b = b[2:]
+					continue
+				}
+				r.out = utf8.AppendRune(r.out, ru)
+				b = b[4:]
+			case u < 0xDC00 && !final:
+				// High surrogate whose pair has not arrived yet.
+				r.nraw = copy(r.raw[:], b)
+				return
+			default:
+				r.out = utf8.AppendRune(r.out, utf8.RuneError)
+				b = b[2:]
+			}
+		}
+	case EncodingUTF32BE, EncodingUTF32LE:
+		for len(b) >= 4 {
+			u := rune(order.Uint32(b))
+			if !utf8.ValidRune(u) {
+				u = utf8.RuneError
+			}
+			r.out = utf8.AppendRune(r.out, u)
+			b = b[4:]
+		}
+	}
+
+	if final && len(b) > 0 {
+		r.out = utf8.AppendRune(r.out, utf8.RuneError)
+		b = nil
+	}
+	r.nraw = copy(r.raw[:], b)
+}
@@ -0,0 +1,56 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBOMReaderStripsMark(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		enc  TextEncoding
+	}{
+		{"\xEF\xBB\xBFtext", "text", EncodingUTF8},
+		{"\xFE\xFF\x00h\x00i", "\x00h\x00i", EncodingUTF16BE},
+		{"\xFF\xFEh\x00i\x00", "h\x00i\x00", EncodingUTF16LE},
+		{"\x00\x00\xFE\xFF\x00\x00\x00h", "\x00\x00\x00h", EncodingUTF32BE},
+		{"\xFF\xFE\x00\x00h\x00\x00\x00", "h\x00\x00\x00", EncodingUTF32LE},
+		{"plain", "plain", EncodingUnknown},
+		{"ab", "ab", EncodingUnknown},
+		{"", "", EncodingUnknown},
+	} {
+		r := NewBOMReader(iotest.OneByteReader(strings.NewReader(tc.in)))
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%q: ReadAll = %q, %v, want %q", tc.in, got, err, tc.want)
+		}
+		if r.DetectedEncoding() != tc.enc {
+			t.Errorf("%q: DetectedEncoding = %v, want %v", tc.in, r.DetectedEncoding(), tc.enc)
+		}
+	}
+}
+
+func TestBOMReaderTranscodes(t *testing.T) {
+	for _, tc := range []struct {
+		name, in, want string
+	}{
+		{"utf-16be", "\xFE\xFF\x00h\x00\xE9\xD8\x3D\xDE\x00", "hé😀"},
+		{"utf-16le", "\xFF\xFEh\x00\xE9\x00\x3D\xD8\x00\xDE", "hé😀"},
+		{"utf-32le", "\xFF\xFE\x00\x00h\x00\x00\x00\x00\xF6\x01\x00", "h😀"},
+		{"lone surrogate", "\xFE\xFF\xD8\x3D\x00h", "�h"},
+		{"odd trailing byte", "\xFE\xFF\x00h\x00", "h�"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewBOMReader(nil)
+			r.Transcode = true
+			r.Reset(iotest.OneByteReader(strings.NewReader(tc.in)))
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != tc.want {
+				t.Fatalf("ReadAll = %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package iochain
+
+import (
+	"io"
+	"sync"
+)
+
+// BypassWriter routes writes either through an inner transform layer or straight to the
+// target, switchable at runtime. The inner layer is flushed when bypass is turned on so
+// none of its buffered bytes are left behind.
+type BypassWriter struct {
+	mu     sync.Mutex
+	inner  ResettableWriter
+	target io.Writer
+	bypass bool
+}
+
+// NewBypassWriter creates a BypassWriter with inner writing to target. Bypass is off.
+func NewBypassWriter(inner ResettableWriter, target io.Writer) (*BypassWriter, error) {
+	if err := inner.Reset(target); err != nil {
+		return nil, err
+	}
+	return &BypassWriter{inner: inner, target: target}, nil
+}
+
+// SetBypass switches between writing to the target directly (true) and through the
+// inner layer (false). Turning bypass on flushes the inner layer first.
+func (b *BypassWriter) SetBypass(bypass bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if bypass && !b.bypass {
+		if flusher, ok := b.inner.(Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	b.bypass = bypass
+	return nil
+}
+
+// Bypassed reports whether writes currently skip the inner layer.
+func (b *BypassWriter) Bypassed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bypass
+}
+
+// Write writes p to the target or the inner layer, depending on the bypass state.
+func (b *BypassWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.bypass {
+		return b.target.Write(p)
+	}
+	return b.inner.Write(p)
+}
+
+// Reset changes the target and rebinds the inner layer to it.
+func (b *BypassWriter) Reset(w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.inner.Reset(w); err != nil {
+		return err
+	}
+	b.target = w
+	return nil
+}
+
+// Flush flushes the inner layer if it implements Flusher.
+func (b *BypassWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if flusher, ok := b.inner.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Close closes the inner layer if it implements io.Closer. The target is not closed.
+func (b *BypassWriter) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if closer, ok := b.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestBypassWriterFlushesInnerWhenToggled(t *testing.T) {
+	var out bytes.Buffer
+	b, err := NewBypassWriter(bufWriterLayer{bufio.NewWriter(nil)}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(s string) {
+		t.Helper()
+		if _, err := b.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("buffered,")
+	if out.Len() != 0 {
+		t.Fatalf("inner layer did not buffer: %q", out.String())
+	}
+	if err := b.SetBypass(true); err != nil {
+		t.Fatal(err)
+	}
+	write("direct,")
+	if !b.Bypassed() || out.String() != "buffered,direct," {
+		t.Fatalf("after bypass: %q, Bypassed = %v", out.String(), b.Bypassed())
+	}
+
+	if err := b.SetBypass(false); err != nil {
+		t.Fatal(err)
+	}
+	write("buffered again")
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "buffered,direct,buffered again"; out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestBypassWriterReset(t *testing.T) {
+	var first, second bytes.Buffer
+	b, err := NewBypassWriter(bufWriterLayer{bufio.NewWriter(nil)}, &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reset(&second); err != nil {
+		t.Fatal(err)
+	}
+	b.Write([]byte("inner "))
+	b.SetBypass(true)
+	b.Write([]byte("direct"))
+	if first.Len() != 0 || second.String() != "inner direct" {
+		t.Fatalf("first = %q, second = %q", first.String(), second.String())
+	}
+}
@@ -0,0 +1,61 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+)
+
+// ChainBuilder declares an ordered list of Layers once and builds matching writer and
+// reader chains from it. Layers are listed from the base outward: the first layer is the
+// one closest to the base on both sides, so the reader chain decodes what the writer
+// chain encoded.
+type ChainBuilder struct {
+	layers []Layer
+}
+
+// NewChainBuilder creates a ChainBuilder with the given layers, base outward.
+func NewChainBuilder(layers ...Layer) *ChainBuilder {
+	return &ChainBuilder{layers: layers}
+}
+
+// Add appends l as the new outermost layer and returns b.
+func (b *ChainBuilder) Add(l Layer) *ChainBuilder {
+	b.layers = append(b.layers, l)
+	return b
+}
+
+// Writer builds a StackWriter on base with a writer for each layer. If a layer fails,
+// the layers already added are closed; base is left open.
+func (b *ChainBuilder) Writer(base io.Writer) (*StackWriter, error) {
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range b.layers {
+		if l == nil {
+			return nil, errors.Join(errors.New("layer cannot be nil"), sw.closeLayers())
+		}
+		if err := sw.AddWriter(LayerWriter(l)); err != nil {
+			return nil, errors.Join(err, sw.closeLayers())
+		}
+	}
+	return sw, nil
+}
+
+// Reader builds a MultiReader on base with a reader for each layer. If a layer fails,
+// the layers already added are closed; base is left open.
+func (b *ChainBuilder) Reader(base io.Reader) (*MultiReader, error) {
+	mr, err := NewReader(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range b.layers {
+		if l == nil {
+			return nil, errors.Join(errors.New("layer cannot be nil"), mr.closeLayers())
+		}
+		if err := mr.AddReader(LayerReader(l)); err != nil {
+			return nil, errors.Join(err, mr.closeLayers())
+		}
+	}
+	return mr, nil
+}
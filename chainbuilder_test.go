@@ -0,0 +1,65 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// closeCountingLayer wraps both sides in values whose Close increments *closed.
+func closeCountingLayer(closed *int) Layer {
+	return LayerFuncs{
+		Writer: func(w io.Writer) (io.Writer, error) {
+			return &countingCloser{Writer: w, closed: closed}, nil
+		},
+		Reader: func(r io.Reader) (io.Reader, error) {
+			return &countingCloser{Reader: r, closed: closed}, nil
+		},
+	}
+}
+
+type countingCloser struct {
+	io.Reader
+	io.Writer
+	closed *int
+}
+
+func (c *countingCloser) Close() error {
+	*c.closed++
+	return nil
+}
+
+var errLayer = errors.New("layer failed")
+
+var failingLayer = LayerFuncs{
+	Writer: func(io.Writer) (io.Writer, error) { return nil, errLayer },
+	Reader: func(io.Reader) (io.Reader, error) { return nil, errLayer },
+}
+
+func TestChainBuilderClosesLayersOnFailure(t *testing.T) {
+	var closed int
+	b := NewChainBuilder(closeCountingLayer(&closed), closeCountingLayer(&closed), failingLayer)
+
+	base := &closeCounter{}
+	if _, err := b.Writer(base); !errors.Is(err, errLayer) {
+		t.Fatalf("Writer = %v, want %v", err, errLayer)
+	}
+	if closed != 2 || base.closed != 0 {
+		t.Fatalf("closed %d writers and the base %d times, want 2 and 0", closed, base.closed)
+	}
+
+	closed = 0
+	if _, err := b.Reader(strings.NewReader("")); !errors.Is(err, errLayer) {
+		t.Fatalf("Reader = %v, want %v", err, errLayer)
+	}
+	if closed != 2 {
+		t.Fatalf("closed %d readers, want 2", closed)
+	}
+
+	closed = 0
+	if _, err := NewChainBuilder(closeCountingLayer(&closed), nil).Writer(&bytes.Buffer{}); err == nil || closed != 1 {
+		t.Fatalf("Writer with a nil layer = %v, closed %d writers", err, closed)
+	}
+}
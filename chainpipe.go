@@ -0,0 +1,85 @@
+package iochain
+
+import "io"
+
+// ChainPipe is an io.Pipe with a StackWriter on its write end and a MultiReader on its
+// read end, so one goroutine can encode through layers while another decodes. Like
+// io.Pipe it has no internal buffering: writes that reach the pipe block until the
+// read side consumes them. Both sides are safe to use from different goroutines.
+//
+// Read-side layers that consume a header on Reset, such as gzip, block in AddReader
+// until the write side has produced it, so add them from the reading goroutine.
+type ChainPipe struct {
+	pr *io.PipeReader
+	pw *io.PipeWriter
+	w  *StackWriter
+	r  *MultiReader
+}
+
+// NewChainPipe creates a ChainPipe with no layers on either side.
+func NewChainPipe() (*ChainPipe, error) {
+	pr, pw := io.Pipe()
+	// The stacks get views of the pipe ends without Close so that ChainPipe decides
+	// which error the other side sees.
+	w, err := NewStackWriter(struct{ io.Writer }{pw})
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(struct{ io.Reader }{pr})
+	if err != nil {
+		return nil, err
+	}
+	return &ChainPipe{pr: pr, pw: pw, w: w, r: r}, nil
+}
+
+// Writer returns the StackWriter of the write end.
+func (p *ChainPipe) Writer() *StackWriter {
+	return p.w
+}
+
+// Reader returns the MultiReader of the read end.
+func (p *ChainPipe) Reader() *MultiReader {
+	return p.r
+}
+
+// Write writes p through the write-side layers.
+func (p *ChainPipe) Write(b []byte) (int, error) {
+	return p.w.Write(b)
+}
+
+// Read reads decoded data from the read side.
+func (p *ChainPipe) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// CloseWrite flushes and closes the write-side layers and then closes the write end.
+// The read side sees io.EOF, or the flush or close error if there was one, which is
+// also returned. It blocks until the read side has consumed the final bytes.
+func (p *ChainPipe) CloseWrite() error {
+	err := p.w.FlushAndClose()
+	_ = p.pw.CloseWithError(err)
+	return err
+}
+
+// CloseWithError aborts the write side: reads on the other end return err, or io.EOF
+// if err is nil. The write-side layers are closed without flushing and their errors
+// are discarded. It always returns nil, like io.PipeWriter.CloseWithError.
+func (p *ChainPipe) CloseWithError(err error) error {
+	// Closing the pipe first unblocks a pending write and keeps layer trailers from blocking.
+	_ = p.pw.CloseWithError(err)
+	_ = p.w.Close()
+	return nil
+}
+
+// CloseRead closes the read-side layers and the read end. Writes that reach the pipe
+// afterwards fail with io.ErrClosedPipe.
+func (p *ChainPipe) CloseRead() error {
+	return p.CloseReadWithError(nil)
+}
+
+// CloseReadWithError closes the read end so that writes that reach the pipe fail with
+// err, or io.ErrClosedPipe if err is nil, and then closes the read-side layers.
+func (p *ChainPipe) CloseReadWithError(err error) error {
+	_ = p.pr.CloseWithError(err)
+	return p.r.Close()
+}
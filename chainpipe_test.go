@@ -0,0 +1,59 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChainPipeGzipRoundTrip(t *testing.T) {
+	p, err := NewChainPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Writer().AddWriter(LayerWriter(gzipLayer)); err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("piped ", 1000)
+	go func() {
+		if _, err := io.WriteString(p, data); err != nil {
+			p.CloseWithError(err)
+			return
+		}
+		p.CloseWrite()
+	}()
+
+	if err := p.Reader().AddReader(LayerReader(gzipLayer)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(p)
+	if err != nil || string(got) != data {
+		t.Fatalf("ReadAll = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestChainPipeErrorPropagation(t *testing.T) {
+	errAbort := errors.New("producer aborted")
+	p, err := NewChainPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.CloseWithError(errAbort)
+	if _, err := io.ReadAll(p); !errors.Is(err, errAbort) {
+		t.Fatalf("read after CloseWithError = %v, want %v", err, errAbort)
+	}
+
+	errGone := errors.New("consumer gone")
+	p, err = NewChainPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CloseReadWithError(errGone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Write([]byte("x")); !errors.Is(err, errGone) {
+		t.Fatalf("write after CloseReadWithError = %v, want %v", err, errGone)
+	}
+}
@@ -0,0 +1,153 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ChainTemplate records the layer constructors of a chain once and stamps out new
+// StackWriters and MultiReaders from it. Layers of chains handed back with Recycle or
+// RecycleReader are pooled and reused through their Reset methods, so compressor and
+// cipher state is not reallocated for every chain. A ChainTemplate is safe for
+// concurrent use once its layers have been added.
+type ChainTemplate struct {
+	newWriters []func() (ResettableWriter, error)
+	newReaders []func() (ResettableReader, error)
+
+	writerSets sync.Pool // []ResettableWriter
+	readerSets sync.Pool // []ResettableReader
+}
+
+// templateLayers links a chain to the ChainTemplate that built it and its layer set.
+type templateLayers struct {
+	template *ChainTemplate
+	set      any
+}
+
+// NewChainTemplate creates an empty ChainTemplate.
+func NewChainTemplate() *ChainTemplate {
+	return &ChainTemplate{}
+}
+
+// AddWriter appends a writer layer constructor, from the base outward, and returns t.
+func (t *ChainTemplate) AddWriter(fn func() (ResettableWriter, error)) *ChainTemplate {
+	t.newWriters = append(t.newWriters, fn)
+	return t
+}
+
+// AddReader appends a reader layer constructor, from the base outward, and returns t.
+func (t *ChainTemplate) AddReader(fn func() (ResettableReader, error)) *ChainTemplate {
+	t.newReaders = append(t.newReaders, fn)
+	return t
+}
+
+// NewWriter builds a StackWriter on base with the template's writer layers, reusing
+// recycled layers when available. If building fails, the layers are closed and those
+// that were all constructed are returned to the pool; base is left open.
+func (t *ChainTemplate) NewWriter(base io.Writer) (*StackWriter, error) {
+	set, _ := t.writerSets.Get().([]ResettableWriter)
+	if set == nil {
+		set = make([]ResettableWriter, len(t.newWriters))
+		for i, fn := range t.newWriters {
+			w, err := fn()
+			if err != nil {
+				return nil, errors.Join(err, closeWriterSet(set[:i]))
+			}
+			set[i] = w
+		}
+	}
+
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.writerSets.Put(set)
+		return nil, err
+	}
+	for i, w := range set {
+		if err := sw.AddWriter(w); err != nil {
+			err = errors.Join(err, sw.closeLayers(), closeWriterSet(set[i:]))
+			t.writerSets.Put(set)
+			return nil, err
+		}
+	}
+	sw.template = &templateLayers{template: t, set: set}
+	return sw, nil
+}
+
+// closeWriterSet closes the writers of a set that are not part of a chain.
+func closeWriterSet(set []ResettableWriter) error {
+	var errs []error
+	for _, w := range set {
+		errs = append(errs, closeLayer(w))
+	}
+	return errors.Join(errs...)
+}
+
+// Recycle hands the layers of sw, which must have been built by NewWriter and closed,
+// back to the template for reuse. sw must not be used afterwards.
+func (t *ChainTemplate) Recycle(sw *StackWriter) error {
+	if sw.Depth() != 0 {
+		return errors.New("cannot recycle a stack that is not closed")
+	}
+	if sw.template == nil || sw.template.template != t {
+		return errors.New("stack was not built by this template")
+	}
+	t.writerSets.Put(sw.template.set)
+	sw.template = nil
+	return nil
+}
+
+// NewReader builds a MultiReader on base with the template's reader layers, reusing
+// recycled layers when available. If building fails, the layers are closed and those
+// that were all constructed are returned to the pool; base is left open.
+func (t *ChainTemplate) NewReader(base io.Reader) (*MultiReader, error) {
+	set, _ := t.readerSets.Get().([]ResettableReader)
+	if set == nil {
+		set = make([]ResettableReader, len(t.newReaders))
+		for i, fn := range t.newReaders {
+			r, err := fn()
+			if err != nil {
+				return nil, errors.Join(err, closeReaderSet(set[:i]))
+			}
+			set[i] = r
+		}
+	}
+
+	mr, err := NewReader(base)
+	if err != nil {
+		t.readerSets.Put(set)
+		return nil, err
+	}
+	for i, r := range set {
+		if err := mr.AddReader(r); err != nil {
+			err = errors.Join(err, mr.closeLayers(), closeReaderSet(set[i:]))
+			t.readerSets.Put(set)
+			return nil, err
+		}
+	}
+	mr.template = &templateLayers{template: t, set: set}
+	return mr, nil
+}
+
+// closeReaderSet closes the readers of a set that are not part of a chain.
+func closeReaderSet(set []ResettableReader) error {
+	var errs []error
+	for _, r := range set {
+		errs = append(errs, closeLayer(r))
+	}
+	return errors.Join(errs...)
+}
+
+// RecycleReader hands the layers of mr, which must have been built by NewReader and
+// closed, back to the template for reuse. mr must not be used afterwards.
+func (t *ChainTemplate) RecycleReader(mr *MultiReader) error {
+	if mr.Depth() != 0 {
+		return errors.New("cannot recycle a chain that is not closed")
+	}
+	if mr.template == nil || mr.template.template != t {
+		return errors.New("chain was not built by this template")
+	}
+	t.readerSets.Put(mr.template.set)
+	mr.template = nil
+	return nil
+}
@@ -0,0 +1,56 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTrackedWriter() (ResettableWriter, error) {
+	return &trackedWriter{TeeWriter{side: io.Discard}}, nil
+}
+
+func newTrackedReader() (ResettableReader, error) {
+	return &trackedReader{}, nil
+}
+
+func TestChainTemplateClosesLayersOnFailure(t *testing.T) {
+	closeTracker.readers, closeTracker.writers = 0, 0
+
+	failingFactory := NewChainTemplate().
+		AddWriter(newTrackedWriter).
+		AddWriter(func() (ResettableWriter, error) { return nil, errLayer }).
+		AddReader(newTrackedReader).
+		AddReader(func() (ResettableReader, error) { return nil, errLayer })
+	if _, err := failingFactory.NewWriter(io.Discard); !errors.Is(err, errLayer) {
+		t.Fatalf("NewWriter = %v, want %v", err, errLayer)
+	}
+	if _, err := failingFactory.NewReader(strings.NewReader("")); !errors.Is(err, errLayer) {
+		t.Fatalf("NewReader = %v, want %v", err, errLayer)
+	}
+	if closeTracker.writers != 1 || closeTracker.readers != 1 {
+		t.Fatalf("closed %d writers and %d readers after a failing factory, want 1 each",
+			closeTracker.writers, closeTracker.readers)
+	}
+
+	// A layer whose Reset fails: the one added before it and the one after it are closed.
+	closeTracker.readers, closeTracker.writers = 0, 0
+	failingReset := NewChainTemplate().
+		AddWriter(newTrackedWriter).
+		AddWriter(func() (ResettableWriter, error) { return LayerWriter(failingLayer), nil }).
+		AddWriter(newTrackedWriter).
+		AddReader(newTrackedReader).
+		AddReader(func() (ResettableReader, error) { return LayerReader(failingLayer), nil }).
+		AddReader(newTrackedReader)
+	if _, err := failingReset.NewWriter(io.Discard); !errors.Is(err, errLayer) {
+		t.Fatalf("NewWriter = %v, want %v", err, errLayer)
+	}
+	if _, err := failingReset.NewReader(strings.NewReader("")); !errors.Is(err, errLayer) {
+		t.Fatalf("NewReader = %v, want %v", err, errLayer)
+	}
+	if closeTracker.writers != 2 || closeTracker.readers != 2 {
+		t.Fatalf("closed %d writers and %d readers after a failing Reset, want 2 each",
+			closeTracker.writers, closeTracker.readers)
+	}
+}
@@ -0,0 +1,116 @@
+// Package chaintest provides layers for testing code built on iochain: a
+// CaptureWriter recording what reaches it, faulty layers injecting errors at chosen
+// offsets, slow layers adding latency and null layers that do nothing.
+package chaintest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*CaptureWriter)(nil)
+	_ iochain.Flusher          = (*CaptureWriter)(nil)
+)
+
+// Call is one method call recorded by CaptureWriter.
+type Call struct {
+	Op  string // "Write", "Flush", "Close" or "Reset"
+	N   int    // bytes accepted, for Write
+	Err error  // error returned downstream, for Write
+}
+
+// CaptureWriter records every byte and call it receives. It works as the base of a
+// StackWriter or as a layer; as a layer it also passes writes downstream. It is safe
+// for concurrent use.
+type CaptureWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	buf   bytes.Buffer
+	calls []Call
+}
+
+// NewCaptureWriter creates a CaptureWriter.
+func NewCaptureWriter() *CaptureWriter {
+	return &CaptureWriter{}
+}
+
+// Write records p and writes it downstream, if there is a downstream writer. Only
+// the bytes the downstream writer accepted are recorded.
+func (c *CaptureWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := len(p), error(nil)
+	if c.w != nil {
+		n, err = c.w.Write(p)
+	}
+	c.buf.Write(p[:n])
+	c.calls = append(c.calls, Call{Op: "Write", N: n, Err: err})
+	return n, err
+}
+
+// Reset records the call and changes the downstream writer.
+func (c *CaptureWriter) Reset(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.w = w
+	c.calls = append(c.calls, Call{Op: "Reset"})
+	return nil
+}
+
+// Flush records the call.
+func (c *CaptureWriter) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Op: "Flush"})
+	return nil
+}
+
+// Close records the call. The downstream writer is not closed.
+func (c *CaptureWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Op: "Close"})
+	return nil
+}
+
+// Bytes returns a copy of the bytes written so far.
+func (c *CaptureWriter) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf.Bytes())
+}
+
+// String returns the bytes written so far as a string.
+func (c *CaptureWriter) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// Calls returns a copy of the calls recorded so far, in order.
+func (c *CaptureWriter) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Ops returns the names of the calls recorded so far, in order, such as
+// ["Reset", "Write", "Flush", "Close"].
+func (c *CaptureWriter) Ops() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ops := make([]string, len(c.calls))
+	for i, call := range c.calls {
+		ops[i] = call.Op
+	}
+	return ops
+}
@@ -0,0 +1,75 @@
+package chaintest
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func TestCaptureWriterAsBase(t *testing.T) {
+	c := NewCaptureWriter()
+	sw, err := iochain.NewStackWriter(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(&NullWriter{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.String() != "hello" {
+		t.Fatalf("captured %q, want %q", c.String(), "hello")
+	}
+	if want := []string{"Write", "Flush", "Close"}; !slices.Equal(c.Ops(), want) {
+		t.Fatalf("Ops() = %v, want %v", c.Ops(), want)
+	}
+}
+
+func TestCaptureWriterAsLayer(t *testing.T) {
+	var base bytes.Buffer
+	sw, err := iochain.NewStackWriter(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCaptureWriter()
+	if err := sw.AddWriter(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("passed")); err != nil {
+		t.Fatal(err)
+	}
+
+	if base.String() != "passed" || c.String() != "passed" {
+		t.Fatalf("base %q, captured %q, want both %q", base.String(), c.String(), "passed")
+	}
+	if want := []string{"Reset", "Write"}; !slices.Equal(c.Ops(), want) {
+		t.Fatalf("Ops() = %v, want %v", c.Ops(), want)
+	}
+}
+
+func TestCaptureWriterRecordsAcceptedBytes(t *testing.T) {
+	f := NewFaultyWriter()
+	f.FailAt = 3
+	f.Reset(&bytes.Buffer{})
+	c := NewCaptureWriter()
+	c.Reset(f)
+
+	n, err := c.Write([]byte("abcdef"))
+	if n != 3 || err != ErrInjected {
+		t.Fatalf("Write = %d, %v, want 3, %v", n, err, ErrInjected)
+	}
+	if string(c.Bytes()) != "abc" {
+		t.Fatalf("captured %q, want %q", c.Bytes(), "abc")
+	}
+	calls := c.Calls()
+	if last := calls[len(calls)-1]; last != (Call{Op: "Write", N: 3, Err: ErrInjected}) {
+		t.Fatalf("last call = %+v, want Write of 3 bytes failing with %v", last, ErrInjected)
+	}
+}
@@ -0,0 +1,148 @@
+package chaintest
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*FaultyWriter)(nil)
+	_ iochain.ResettableReader = (*FaultyReader)(nil)
+)
+
+// ErrInjected is the error injected by the faulty layers unless another one is set.
+var ErrInjected = errors.New("chaintest: injected fault")
+
+// FaultyWriter is a pass-through layer that fails at chosen points. Offsets count the
+// bytes passed downstream since the last Reset. Set the fields before use.
+type FaultyWriter struct {
+	// FailAt makes writes fail once the stream reaches this offset: the bytes before
+	// it are passed on and the error is returned. Negative disables it.
+	FailAt int64
+	// ShortAt makes the write crossing this offset stop there and report a short
+	// write without an error, once. Negative disables it.
+	ShortAt int64
+	// Err is the error returned at FailAt; ErrInjected if nil.
+	Err error
+	// FlushErr, CloseErr and ResetErr are returned by Flush, Close and Reset.
+	FlushErr error
+	CloseErr error
+	ResetErr error
+
+	w   io.Writer
+	off int64
+}
+
+// NewFaultyWriter creates a FaultyWriter with every fault disabled.
+func NewFaultyWriter() *FaultyWriter {
+	return &FaultyWriter{FailAt: -1, ShortAt: -1}
+}
+
+// Write passes p downstream up to the next fault.
+func (f *FaultyWriter) Write(p []byte) (int, error) {
+	if f.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+
+	end := f.off + int64(len(p))
+	var err error
+	switch {
+	case f.ShortAt >= f.off && end > f.ShortAt && (f.FailAt < 0 || f.ShortAt < f.FailAt):
+		p = p[:f.ShortAt-f.off]
+		f.ShortAt = -1
+	case f.FailAt >= 0 && end > f.FailAt:
+		p = p[:max(f.FailAt-f.off, 0)]
+		err = f.Err
+		if err == nil {
+			err = ErrInjected
+		}
+	}
+
+	n, werr := f.w.Write(p)
+	f.off += int64(n)
+	if werr != nil {
+		return n, werr
+	}
+	return n, err
+}
+
+// Reset returns ResetErr if set, otherwise changes the downstream writer and restarts
+// the offset count.
+func (f *FaultyWriter) Reset(w io.Writer) error {
+	if f.ResetErr != nil {
+		return f.ResetErr
+	}
+	f.w = w
+	f.off = 0
+	return nil
+}
+
+// Flush returns FlushErr.
+func (f *FaultyWriter) Flush() error {
+	return f.FlushErr
+}
+
+// Close returns CloseErr. The downstream writer is not closed.
+func (f *FaultyWriter) Close() error {
+	return f.CloseErr
+}
+
+// FaultyReader is a pass-through layer that fails at a chosen offset, counting the
+// bytes returned since the last Reset. Set the fields before use.
+type FaultyReader struct {
+	// FailAt makes reads fail once the stream reaches this offset: the bytes before
+	// it are returned and then the error. Negative disables it.
+	FailAt int64
+	// Err is the error returned at FailAt; ErrInjected if nil.
+	Err error
+	// CloseErr and ResetErr are returned by Close and Reset.
+	CloseErr error
+	ResetErr error
+
+	r   io.Reader
+	off int64
+}
+
+// NewFaultyReader creates a FaultyReader with every fault disabled.
+func NewFaultyReader() *FaultyReader {
+	return &FaultyReader{FailAt: -1}
+}
+
+// Read reads from the source up to the fault.
+func (f *FaultyReader) Read(p []byte) (int, error) {
+	if f.r == nil {
+		return 0, io.EOF
+	}
+	if f.FailAt >= 0 {
+		if f.off >= f.FailAt {
+			if f.Err != nil {
+				return 0, f.Err
+			}
+			return 0, ErrInjected
+		}
+		if remaining := f.FailAt - f.off; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := f.r.Read(p)
+	f.off += int64(n)
+	return n, err
+}
+
+// Reset returns ResetErr if set, otherwise changes the source and restarts the offset
+// count.
+func (f *FaultyReader) Reset(src io.Reader) error {
+	if f.ResetErr != nil {
+		return f.ResetErr
+	}
+	f.r = src
+	f.off = 0
+	return nil
+}
+
+// Close returns CloseErr. The source is not closed.
+func (f *FaultyReader) Close() error {
+	return f.CloseErr
+}
@@ -0,0 +1,122 @@
+package chaintest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func TestFaultyWriterFailAt(t *testing.T) {
+	var base bytes.Buffer
+	sw, err := iochain.NewStackWriter(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFaultyWriter()
+	f.FailAt = 5
+	if err := sw.AddWriter(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sw.Write([]byte("hello world")); !errors.Is(err, ErrInjected) {
+		t.Fatalf("Write error = %v, want %v", err, ErrInjected)
+	}
+	if base.String() != "hello" {
+		t.Fatalf("base got %q, want %q", base.String(), "hello")
+	}
+}
+
+func TestFaultyWriterShortAt(t *testing.T) {
+	var base bytes.Buffer
+	f := NewFaultyWriter()
+	f.ShortAt = 3
+	f.Reset(&base)
+
+	if n, err := f.Write([]byte("abcdef")); n != 3 || err != nil {
+		t.Fatalf("first Write = %d, %v, want 3, nil", n, err)
+	}
+	if n, err := f.Write([]byte("def")); n != 3 || err != nil {
+		t.Fatalf("second Write = %d, %v, want 3, nil", n, err)
+	}
+	if base.String() != "abcdef" {
+		t.Fatalf("base got %q, want %q", base.String(), "abcdef")
+	}
+}
+
+func TestFaultyWriterResetRestartsOffset(t *testing.T) {
+	errCustom := errors.New("custom")
+	f := NewFaultyWriter()
+	f.FailAt = 2
+	f.Err = errCustom
+	f.Reset(io.Discard)
+
+	if _, err := f.Write([]byte("abc")); err != errCustom {
+		t.Fatalf("Write error = %v, want %v", err, errCustom)
+	}
+	var base bytes.Buffer
+	f.Reset(&base)
+	if n, err := f.Write([]byte("ab")); n != 2 || err != nil {
+		t.Fatalf("Write after Reset = %d, %v, want 2, nil", n, err)
+	}
+}
+
+func TestFaultyWriterMethodErrors(t *testing.T) {
+	errFlush, errClose, errReset := errors.New("flush"), errors.New("close"), errors.New("reset")
+	sw, err := iochain.NewStackWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFaultyWriter()
+	f.ResetErr = errReset
+	if err := sw.AddWriter(f); !errors.Is(err, errReset) {
+		t.Fatalf("AddWriter error = %v, want %v", err, errReset)
+	}
+
+	f = NewFaultyWriter()
+	f.FlushErr, f.CloseErr = errFlush, errClose
+	if err := sw.AddWriter(f); err != nil {
+		t.Fatal(err)
+	}
+	err = sw.FlushAndClose()
+	if !errors.Is(err, errFlush) || !errors.Is(err, errClose) {
+		t.Fatalf("FlushAndClose error = %v, want %v and %v", err, errFlush, errClose)
+	}
+}
+
+func TestFaultyReaderFailAt(t *testing.T) {
+	mr, err := iochain.NewReader(strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFaultyReader()
+	f.FailAt = 5
+	if err := mr.AddReader(f); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(mr)
+	if !errors.Is(err, ErrInjected) {
+		t.Fatalf("ReadAll error = %v, want %v", err, ErrInjected)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("read %q, want %q", got, "hello")
+	}
+}
+
+func TestFaultyReaderResetErr(t *testing.T) {
+	errReset := errors.New("reset")
+	mr, err := iochain.NewReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFaultyReader()
+	f.ResetErr = errReset
+	if err := mr.AddReader(f); !errors.Is(err, errReset) {
+		t.Fatalf("AddReader error = %v, want %v", err, errReset)
+	}
+}
@@ -0,0 +1,65 @@
+package chaintest
+
+import (
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*NullWriter)(nil)
+	_ iochain.ResettableWriter = (*DiscardWriter)(nil)
+	_ iochain.ResettableReader = (*NullReader)(nil)
+)
+
+// NullWriter is a layer that passes writes downstream unchanged.
+type NullWriter struct {
+	w io.Writer
+}
+
+// Write writes p downstream.
+func (n *NullWriter) Write(p []byte) (int, error) {
+	if n.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return n.w.Write(p)
+}
+
+// Reset changes the downstream writer.
+func (n *NullWriter) Reset(w io.Writer) error {
+	n.w = w
+	return nil
+}
+
+// DiscardWriter is a layer that accepts and drops every write, so nothing reaches the
+// layers below it.
+type DiscardWriter struct{}
+
+// Write discards p.
+func (DiscardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Reset does nothing.
+func (DiscardWriter) Reset(io.Writer) error {
+	return nil
+}
+
+// NullReader is a layer that passes reads through unchanged.
+type NullReader struct {
+	r io.Reader
+}
+
+// Read reads from the source.
+func (n *NullReader) Read(p []byte) (int, error) {
+	if n.r == nil {
+		return 0, io.EOF
+	}
+	return n.r.Read(p)
+}
+
+// Reset changes the source.
+func (n *NullReader) Reset(src io.Reader) error {
+	n.r = src
+	return nil
+}
@@ -0,0 +1,69 @@
+package chaintest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func TestNullWriter(t *testing.T) {
+	var base bytes.Buffer
+	sw, err := iochain.NewStackWriter(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(&NullWriter{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("unchanged")); err != nil {
+		t.Fatal(err)
+	}
+	if base.String() != "unchanged" {
+		t.Fatalf("base got %q, want %q", base.String(), "unchanged")
+	}
+
+	if _, err := (&NullWriter{}).Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Fatalf("Write before Reset = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestDiscardWriter(t *testing.T) {
+	var base bytes.Buffer
+	sw, err := iochain.NewStackWriter(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(DiscardWriter{}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := sw.Write([]byte("dropped")); n != 7 || err != nil {
+		t.Fatalf("Write = %d, %v, want 7, nil", n, err)
+	}
+	if base.Len() != 0 {
+		t.Fatalf("base got %q, want nothing", base.String())
+	}
+}
+
+func TestNullReader(t *testing.T) {
+	mr, err := iochain.NewReader(strings.NewReader("unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(&NullReader{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "unchanged" {
+		t.Fatalf("read %q, want %q", got, "unchanged")
+	}
+
+	if _, err := (&NullReader{}).Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Read before Reset = %v, want %v", err, io.EOF)
+	}
+}
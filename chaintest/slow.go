@@ -0,0 +1,80 @@
+package chaintest
+
+import (
+	"io"
+	"time"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*SlowWriter)(nil)
+	_ iochain.ResettableReader = (*SlowReader)(nil)
+)
+
+// SlowWriter is a pass-through layer that waits before every write.
+type SlowWriter struct {
+	// Delay is the wait before each write.
+	Delay time.Duration
+	// Sleep waits for Delay; time.Sleep if nil. Replace it to control time in tests.
+	Sleep func(time.Duration)
+
+	w io.Writer
+}
+
+// NewSlowWriter creates a SlowWriter waiting delay before each write.
+func NewSlowWriter(delay time.Duration) *SlowWriter {
+	return &SlowWriter{Delay: delay}
+}
+
+// Write waits and then writes p downstream.
+func (s *SlowWriter) Write(p []byte) (int, error) {
+	if s.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	sleep(s.Sleep, s.Delay)
+	return s.w.Write(p)
+}
+
+// Reset changes the downstream writer.
+func (s *SlowWriter) Reset(w io.Writer) error {
+	s.w = w
+	return nil
+}
+
+// SlowReader is a pass-through layer that waits before every read.
+type SlowReader struct {
+	// Delay is the wait before each read.
+	Delay time.Duration
+	// Sleep waits for Delay; time.Sleep if nil. Replace it to control time in tests.
+	Sleep func(time.Duration)
+
+	r io.Reader
+}
+
+// NewSlowReader creates a SlowReader waiting delay before each read.
+func NewSlowReader(delay time.Duration) *SlowReader {
+	return &SlowReader{Delay: delay}
+}
+
+// Read waits and then reads from the source.
+func (s *SlowReader) Read(p []byte) (int, error) {
+	if s.r == nil {
+		return 0, io.EOF
+	}
+	sleep(s.Sleep, s.Delay)
+	return s.r.Read(p)
+}
+
+// Reset changes the source.
+func (s *SlowReader) Reset(src io.Reader) error {
+	s.r = src
+	return nil
+}
+
+func sleep(fn func(time.Duration), d time.Duration) {
+	if fn == nil {
+		fn = time.Sleep
+	}
+	fn(d)
+}
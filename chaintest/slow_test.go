@@ -0,0 +1,62 @@
+package chaintest
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func TestSlowWriter(t *testing.T) {
+	var base bytes.Buffer
+	sw, err := iochain.NewStackWriter(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept []time.Duration
+	s := NewSlowWriter(time.Second)
+	s.Sleep = func(d time.Duration) { slept = append(slept, d) }
+	if err := sw.AddWriter(s); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"slow", "writes"} {
+		if _, err := sw.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.String() != "slowwrites" {
+		t.Fatalf("base got %q, want %q", base.String(), "slowwrites")
+	}
+	if want := []time.Duration{time.Second, time.Second}; !slices.Equal(slept, want) {
+		t.Fatalf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestSlowReader(t *testing.T) {
+	mr, err := iochain.NewReader(strings.NewReader("slow reads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept []time.Duration
+	s := NewSlowReader(time.Millisecond)
+	s.Sleep = func(d time.Duration) { slept = append(slept, d) }
+	if err := mr.AddReader(s); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "slow reads" {
+		t.Fatalf("read %q, want %q", got, "slow reads")
+	}
+	if len(slept) == 0 || slept[0] != time.Millisecond {
+		t.Fatalf("slept %v, want waits of %v", slept, time.Millisecond)
+	}
+}
@@ -0,0 +1,53 @@
+package iochain
+
+import (
+	"bytes"
+	"hash"
+	"io"
+)
+
+var _ ResettableReader = (*ChecksumReader)(nil)
+
+// ChecksumReader hashes the bytes read through it, so a stream can be verified as it
+// is consumed.
+type ChecksumReader struct {
+	src io.Reader
+	h   hash.Hash
+}
+
+// NewChecksumReader creates a ChecksumReader hashing with h. The source is set with
+// Reset, typically by MultiReader.AddReader.
+func NewChecksumReader(h hash.Hash) *ChecksumReader {
+	return &ChecksumReader{h: h}
+}
+
+// Read reads from the source and hashes the bytes returned.
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	if c.src == nil {
+		return 0, io.EOF
+	}
+	n, err := c.src.Read(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// Reset changes the source and resets the hash for a new stream.
+func (c *ChecksumReader) Reset(src io.Reader) error {
+	c.src = src
+	c.h.Reset()
+	return nil
+}
+
+// Sum returns the digest of the bytes read so far.
+func (c *ChecksumReader) Sum() []byte {
+	return c.h.Sum(nil)
+}
+
+// Verify returns ErrChecksumMismatch unless Sum equals expected. Call it after reading
+// to io.EOF.
+func (c *ChecksumReader) Verify(expected []byte) error {
+	if !bytes.Equal(c.Sum(), expected) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
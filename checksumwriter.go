@@ -0,0 +1,58 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+)
+
+var _ ResettableWriter = (*ChecksumWriter)(nil)
+
+// ErrChecksumMismatch is returned by Verify when the digest differs from the expected one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumWriter hashes the bytes its downstream writer accepts, so the digest of a
+// stream is available once it has been written, without a second pass.
+type ChecksumWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+// NewChecksumWriter creates a ChecksumWriter hashing with h, such as sha256.New() or
+// crc32.NewIEEE(). The downstream writer is set with Reset, typically by
+// StackWriter.AddWriter.
+func NewChecksumWriter(h hash.Hash) *ChecksumWriter {
+	return &ChecksumWriter{h: h}
+}
+
+// Write writes p downstream and hashes the accepted bytes.
+func (c *ChecksumWriter) Write(p []byte) (int, error) {
+	if c.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := c.w.Write(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// Reset changes the downstream writer and resets the hash for a new stream.
+func (c *ChecksumWriter) Reset(w io.Writer) error {
+	c.w = w
+	c.h.Reset()
+	return nil
+}
+
+// Sum returns the digest of the bytes written so far. Layers above the ChecksumWriter
+// should be flushed or closed first so their buffered bytes are included.
+func (c *ChecksumWriter) Sum() []byte {
+	return c.h.Sum(nil)
+}
+
+// Verify returns ErrChecksumMismatch unless Sum equals expected.
+func (c *ChecksumWriter) Verify(expected []byte) error {
+	if !bytes.Equal(c.Sum(), expected) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package iochain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChecksumLayersRoundTrip(t *testing.T) {
+	data := strings.Repeat("checksummed ", 500)
+	want := sha256.Sum256([]byte(data))
+
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw := NewChecksumWriter(sha256.New())
+	if err := sw.AddWriter(cw); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(sw, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Verify(want[:]); err != nil {
+		t.Fatalf("writer Verify = %v", err)
+	}
+
+	mr, err := NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := NewChecksumReader(sha256.New())
+	if err := mr.AddReader(cr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, mr); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.Verify(want[:]); err != nil {
+		t.Fatalf("reader Verify = %v", err)
+	}
+	if err := cr.Verify(make([]byte, len(want))); err != ErrChecksumMismatch {
+		t.Fatalf("Verify of a wrong digest = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestChecksumWriterHashesAcceptedBytes(t *testing.T) {
+	cw := NewChecksumWriter(crc32.NewIEEE())
+	if err := cw.Reset(&shortWriter{limit: 4}); err != nil {
+		t.Fatal(err)
+	}
+	cw.Write([]byte("accepted, not this"))
+	want := crc32.NewIEEE()
+	want.Write([]byte("acce"))
+	if err := cw.Verify(want.Sum(nil)); err != nil {
+		t.Fatal("ChecksumWriter hashed bytes its downstream rejected")
+	}
+
+	cw.Reset(io.Discard)
+	if err := cw.Verify(crc32.NewIEEE().Sum(nil)); err != nil {
+		t.Fatal("Reset did not restart the hash")
+	}
+}
@@ -0,0 +1,24 @@
+package iochain
+
+import "io"
+
+// ChunkingWriter splits a stream into fixed-size parts, such as the parts of a
+// multipart upload. It is another name for SpanningWriter: each part is closed before
+// the next one is created. Used as the base of a StackWriter, every part comes from
+// the factory and the last one is closed when the stack is closed.
+type ChunkingWriter = SpanningWriter
+
+// ChunkedReader concatenates parts written by a ChunkingWriter. It is another name for
+// SpannedReader.
+type ChunkedReader = SpannedReader
+
+// NewChunkingWriter creates a ChunkingWriter creating parts of partSize bytes with create.
+func NewChunkingWriter(create func(partIndex int) (io.WriteCloser, error), partSize int64) *ChunkingWriter {
+	return NewSpanningWriter(create, partSize)
+}
+
+// NewChunkedReader creates a ChunkedReader opening parts with open, which returns
+// io.EOF once there are no more parts.
+func NewChunkedReader(open func(partIndex int) (io.ReadCloser, error)) *ChunkedReader {
+	return NewSpannedReader(open)
+}
@@ -0,0 +1,54 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChunkingWriterAsBaseRoundTrip(t *testing.T) {
+	var parts []*closeCounter
+	create := func(index int) (io.WriteCloser, error) {
+		if index > 0 && parts[index-1].closed != 1 {
+			t.Errorf("part %d created before part %d was closed", index, index-1)
+		}
+		p := &closeCounter{}
+		parts = append(parts, p)
+		return p, nil
+	}
+
+	sw, err := NewStackWriter(NewChunkingWriter(create, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewHexWriter()); err != nil {
+		t.Fatal(err)
+	}
+	data := "chunked into parts"
+	if _, err := io.WriteString(sw, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 5 || parts[4].closed != 1 {
+		t.Fatalf("%d parts, last closed %v, want 5 with the last closed", len(parts), parts[len(parts)-1].closed)
+	}
+
+	mr, err := NewReader(NewChunkedReader(func(index int) (io.ReadCloser, error) {
+		if index >= len(parts) {
+			return nil, io.EOF
+		}
+		return io.NopCloser(strings.NewReader(parts[index].String())), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(NewHexReader()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != data {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+}
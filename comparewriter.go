@@ -0,0 +1,120 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+)
+
+// CompareWriter checks the written stream against an expected reader and records the
+// offset of the first difference. If a passthrough writer is set, data is also written to it
+// and only the bytes it accepted are compared.
+type CompareWriter struct {
+	expected    io.Reader
+	passthrough io.Writer
+
+	offset  int64  // bytes compared so far
+	pending []byte // written bytes not yet compared because reading expected failed
+	diff    int64
+	differs bool
+	buf     []byte
+}
+
+// NewCompareWriter creates a CompareWriter comparing against expected.
+// passthrough may be nil.
+func NewCompareWriter(expected io.Reader, passthrough io.Writer) *CompareWriter {
+	return &CompareWriter{expected: expected, passthrough: passthrough}
+}
+
+// Write compares p against the next bytes of the expected stream.
+func (c *CompareWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	var err error
+	if c.passthrough != nil {
+		n, err = c.passthrough.Write(p)
+	}
+	if cerr := c.compare(p[:n]); cerr != nil && err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Reset changes the passthrough writer.
+func (c *CompareWriter) Reset(w io.Writer) error {
+	c.passthrough = w
+	return nil
+}
+
+// FirstDiff returns the offset of the first byte that differs from the expected stream.
+// ok is false if no difference has been found so far. A written stream that is shorter
+// than the expected one is only detected by Close.
+func (c *CompareWriter) FirstDiff() (offset int64, ok bool) {
+	return c.diff, c.differs
+}
+
+// Close checks that the expected stream has no bytes left and closes it if it
+// implements io.Closer. The passthrough writer is not closed.
+func (c *CompareWriter) Close() error {
+	if !c.differs {
+		var b [1]byte
+		if n, _ := io.ReadFull(c.expected, b[:]); n > 0 {
+			c.mark(c.offset)
+		}
+	}
+	if closer, ok := c.expected.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// compare reads len(p) expected bytes and compares them with p. Running out of expected
+// bytes counts as a difference; other read errors are returned, and the bytes of p that
+// were not compared are kept for the next call so the streams stay aligned.
+func (c *CompareWriter) compare(p []byte) error {
+	if c.differs {
+		return nil
+	}
+	if len(c.pending) > 0 {
+		p = append(c.pending, p...)
+		c.pending = nil
+	}
+	if cap(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	exp := c.buf[:len(p)]
+
+	n, err := io.ReadFull(c.expected, exp)
+	if i := mismatch(p[:n], exp[:n]); i >= 0 {
+		c.mark(c.offset + int64(i))
+		return nil
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		c.offset += int64(n)
+		c.pending = append([]byte(nil), p[n:]...)
+		return err
+	}
+	if n < len(p) {
+		// The expected stream ended early.
+		c.mark(c.offset + int64(n))
+		return nil
+	}
+	c.offset += int64(n)
+	return nil
+}
+
+func (c *CompareWriter) mark(offset int64) {
+	c.diff = offset
+	c.differs = true
+}
+
+// mismatch returns the index of the first differing byte of a and b, or -1.
+func mismatch(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// hiccupReader returns at most limit bytes and then err once, before reading on normally.
+type hiccupReader struct {
+	r     io.Reader
+	limit int
+	err   error
+}
+
+func (h *hiccupReader) Read(p []byte) (int, error) {
+	if h.err == nil {
+		return h.r.Read(p)
+	}
+	n, _ := h.r.Read(p[:min(len(p), h.limit)])
+	h.limit -= n
+	if h.limit > 0 {
+		return n, nil
+	}
+	err := h.err
+	h.err = nil
+	return n, err
+}
+
+func TestCompareWriterStaysAlignedAfterReadError(t *testing.T) {
+	const data = "0123456789abcdef"
+	errRead := errors.New("expected stream hiccup")
+	c := NewCompareWriter(&hiccupReader{r: strings.NewReader(data), limit: 3, err: errRead}, nil)
+
+	if _, err := c.Write([]byte(data[:8])); !errors.Is(err, errRead) {
+		t.Fatalf("Write = %v, want %v", err, errRead)
+	}
+	if _, err := c.Write([]byte(data[8:])); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if off, ok := c.FirstDiff(); ok {
+		t.Fatalf("identical streams differ at %d", off)
+	}
+}
+
+func TestCompareWriterFindsDifference(t *testing.T) {
+	c := NewCompareWriter(strings.NewReader("abcdef"), nil)
+	c.Write([]byte("abc"))
+	c.Write([]byte("dXf"))
+	if off, ok := c.FirstDiff(); !ok || off != 4 {
+		t.Fatalf("FirstDiff = %d, %v, want 4, true", off, ok)
+	}
+}
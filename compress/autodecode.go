@@ -0,0 +1,137 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var _ iochain.ResettableReader = (*AutoDecodeReader)(nil)
+
+// Formats detected by AutoDecodeReader.
+const (
+	FormatNone  = "none"
+	FormatGzip  = "gzip"
+	FormatZlib  = "zlib"
+	FormatBzip2 = "bzip2"
+	FormatZstd  = "zstd"
+)
+
+// ErrZstdUnsupported is returned by AutoDecodeReader for zstd input when the package was
+// built without the zstd build tag.
+var ErrZstdUnsupported = errors.New("compress: zstd support requires the zstd build tag")
+
+// newZstdDecoder is set when zstd support is built in.
+var newZstdDecoder func(r io.Reader) (io.ReadCloser, error)
+
+// AutoDecodeReader detects the compression of its source from the leading magic bytes
+// and decompresses it with the matching decoder: gzip, zlib, bzip2 or zstd. Sources
+// that match none of them, or whose header the matching decoder rejects, are passed
+// through unchanged.
+type AutoDecodeReader struct {
+	r      io.Reader
+	format string
+}
+
+// NewAutoDecodeReader creates an AutoDecodeReader. The source is set with Reset,
+// typically by MultiReader.AddReader, which performs the detection.
+func NewAutoDecodeReader() *AutoDecodeReader {
+	return &AutoDecodeReader{}
+}
+
+// Read reads decompressed data.
+func (a *AutoDecodeReader) Read(p []byte) (int, error) {
+	if a.r == nil {
+		return 0, io.EOF
+	}
+	return a.r.Read(p)
+}
+
+// Reset closes the previous decoder, then detects the format of src and starts decoding it.
+func (a *AutoDecodeReader) Reset(src io.Reader) error {
+	if err := a.Close(); err != nil {
+		return err
+	}
+	a.r, a.format = nil, ""
+
+	br := bufio.NewReader(src)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// Decoders that reject the stream while reading its header fall back to passing it
+	// through, so the bytes they consumed are recorded to be replayed.
+	rec := &replayReader{r: br, recording: true}
+	var dec io.Reader
+	format := FormatNone
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		format = FormatGzip
+		dec, err = gzip.NewReader(rec)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		if newZstdDecoder == nil {
+			return ErrZstdUnsupported
+		}
+		format = FormatZstd
+		dec, err = newZstdDecoder(rec)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		format = FormatBzip2
+		dec, err = bzip2.NewReader(rec), nil
+	case isZlibHeader(magic):
+		format = FormatZlib
+		dec, err = zlib.NewReader(rec)
+	}
+	rec.recording = false
+
+	if format == FormatNone || err != nil {
+		a.r, a.format = io.MultiReader(bytes.NewReader(rec.buf), br), FormatNone
+		return nil
+	}
+	a.r, a.format = dec, format
+	return nil
+}
+
+// replayReader records the bytes read through it while recording is set.
+type replayReader struct {
+	r         io.Reader
+	buf       []byte
+	recording bool
+}
+
+func (p *replayReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if p.recording {
+		p.buf = append(p.buf, b[:n]...)
+	}
+	return n, err
+}
+
+// Format returns the detected format, one of the Format constants, or "" before Reset.
+func (a *AutoDecodeReader) Format() string {
+	return a.format
+}
+
+// Close releases the decoder. The source is not closed.
+func (a *AutoDecodeReader) Close() error {
+	if closer, ok := a.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// isZlibHeader reports whether magic starts with a zlib header using deflate and no
+// preset dictionary, which is what zlib.NewReader can decode.
+func isZlibHeader(magic []byte) bool {
+	if len(magic) < 2 {
+		return false
+	}
+	return magic[0]&0x0f == 8 && magic[0]>>4 <= 7 && magic[1]&0x20 == 0 &&
+		(uint16(magic[0])<<8|uint16(magic[1]))%31 == 0
+}
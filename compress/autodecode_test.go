@@ -0,0 +1,85 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func autoDecode(t *testing.T, src []byte) (string, string) {
+	t.Helper()
+	mr, err := iochain.NewReader(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad := NewAutoDecodeReader()
+	if err := mr.AddReader(ad); err != nil {
+		t.Fatalf("AddReader(%q): %v", src, err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil {
+		t.Fatalf("ReadAll(%q): %v", src, err)
+	}
+	return ad.Format(), string(got)
+}
+
+func TestAutoDecodeDetectsFormats(t *testing.T) {
+	const plain = "auto-detected payload\n"
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(plain))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(plain))
+	zw.Close()
+	// "BZh9" followed by the empty-stream trailer decodes to nothing.
+	bz := []byte{'B', 'Z', 'h', '9', 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0, 0, 0, 0}
+
+	tests := []struct {
+		src    []byte
+		format string
+		want   string
+	}{
+		{gz.Bytes(), FormatGzip, plain},
+		{zl.Bytes(), FormatZlib, plain},
+		{bz, FormatBzip2, ""},
+	}
+	for _, tt := range tests {
+		format, got := autoDecode(t, tt.src)
+		if format != tt.format || got != tt.want {
+			t.Errorf("format %q, data %q; want %q, %q", format, got, tt.format, tt.want)
+		}
+	}
+}
+
+func TestAutoDecodePassesPlainTextThrough(t *testing.T) {
+	inputs := []string{
+		"",
+		"hi",
+		"plain text\n",
+		"x = 5\n",     // 0x78 0x20 passes the zlib checksum but sets FDICT
+		"800 lines\n", // 0x38 0x30 likewise
+		"\x1f\x8bnot really gzip",
+	}
+	for _, in := range inputs {
+		format, got := autoDecode(t, []byte(in))
+		if format != FormatNone || got != in {
+			t.Errorf("input %q: format %q, data %q", in, format, got)
+		}
+	}
+}
+
+func TestIsZlibHeaderRejectsPresetDictionary(t *testing.T) {
+	if !isZlibHeader([]byte{0x78, 0x9c}) {
+		t.Fatal("default zlib header not detected")
+	}
+	for _, h := range []string{"x ", "80", "x\xbb"} {
+		if isZlibHeader([]byte(h)) {
+			t.Errorf("%q detected as zlib", h)
+		}
+	}
+}
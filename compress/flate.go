@@ -0,0 +1,86 @@
+package compress
+
+import (
+	"compress/flate"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*FlateWriter)(nil)
+	_ iochain.Flusher          = (*FlateWriter)(nil)
+	_ iochain.ResettableReader = (*FlateReader)(nil)
+)
+
+func init() {
+	iochain.RegisterFormat("flate", iochain.Format{
+		Keys: []string{"level"},
+		NewReader: func(iochain.Options) (iochain.ResettableReader, error) {
+			return NewFlateReader(), nil
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			level, err := opts.Int("level", flate.DefaultCompression)
+			if err != nil {
+				return nil, err
+			}
+			return NewFlateWriter(level)
+		},
+	})
+}
+
+// FlateWriter is a flate.Writer whose Reset returns an error, as iochain.ResettableWriter requires.
+type FlateWriter struct {
+	*flate.Writer
+}
+
+// NewFlateWriter creates a FlateWriter compressing at level, one of the flate package's levels.
+// The destination is set with Reset, typically by StackWriter.AddWriter.
+func NewFlateWriter(level int) (*FlateWriter, error) {
+	w, err := flate.NewWriter(nil, level)
+	if err != nil {
+		return nil, err
+	}
+	return &FlateWriter{Writer: w}, nil
+}
+
+// Reset discards any pending state and starts a new raw deflate stream on dst.
+func (w *FlateWriter) Reset(dst io.Writer) error {
+	w.Writer.Reset(dst)
+	return nil
+}
+
+// FlateReader decompresses a raw deflate stream.
+type FlateReader struct {
+	rc io.ReadCloser
+}
+
+// NewFlateReader creates a FlateReader. The source is set with Reset, typically by
+// MultiReader.AddReader.
+func NewFlateReader() *FlateReader {
+	return &FlateReader{}
+}
+
+func (r *FlateReader) Read(p []byte) (int, error) {
+	if r.rc == nil {
+		return 0, io.EOF
+	}
+	return r.rc.Read(p)
+}
+
+// Reset starts decompressing src.
+func (r *FlateReader) Reset(src io.Reader) error {
+	if r.rc == nil {
+		r.rc = flate.NewReader(src)
+		return nil
+	}
+	return r.rc.(flate.Resetter).Reset(src, nil)
+}
+
+// Close releases the decompressor. The source is not closed.
+func (r *FlateReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	return r.rc.Close()
+}
@@ -0,0 +1,47 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func TestRegisteredFormatsRoundTrip(t *testing.T) {
+	data := strings.Repeat("registered format ", 100)
+	for _, spec := range []string{"gzip", "gzip:level=9", "zlib", "flate:level=1", "zlib|flate"} {
+		var buf bytes.Buffer
+		sw, err := iochain.BuildWriterChain(&buf, spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if _, err := io.WriteString(sw, data); err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if err := sw.FlushAndClose(); err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+
+		mr, err := iochain.BuildReaderChain(&buf, spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		got, err := io.ReadAll(mr)
+		if err != nil || string(got) != data {
+			t.Fatalf("%s: read back %d bytes, %v", spec, len(got), err)
+		}
+	}
+}
+
+func TestRegisteredFormatsRejectUnknownOptions(t *testing.T) {
+	for _, spec := range []string{"gzip:levle=9", "zlib:lvl=1", "flate:workers=2", "pgzip:worker=2"} {
+		if _, err := iochain.BuildWriterChain(io.Discard, spec); err == nil {
+			t.Errorf("BuildWriterChain(%q) accepted an unknown option", spec)
+		}
+		if _, err := iochain.BuildReaderChain(strings.NewReader(""), spec); err == nil {
+			t.Errorf("BuildReaderChain(%q) accepted an unknown option", spec)
+		}
+	}
+}
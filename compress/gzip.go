@@ -0,0 +1,90 @@
+// Package compress provides compression layers for iochain writer and reader chains.
+//
+// The gzip, zlib and flate layers are always available. The zstd layer is built only with the
+// zstd build tag, which pulls in github.com/klauspost/compress.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*GzipWriter)(nil)
+	_ iochain.Flusher          = (*GzipWriter)(nil)
+	_ iochain.ResettableReader = (*GzipReader)(nil)
+)
+
+func init() {
+	iochain.RegisterFormat("gzip", iochain.Format{
+		Keys: []string{"level"},
+		NewReader: func(iochain.Options) (iochain.ResettableReader, error) {
+			return NewGzipReader(), nil
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			level, err := opts.Int("level", gzip.DefaultCompression)
+			if err != nil {
+				return nil, err
+			}
+			return NewGzipWriter(level)
+		},
+	})
+}
+
+// GzipWriter is a gzip.Writer whose Reset returns an error, as iochain.ResettableWriter requires.
+type GzipWriter struct {
+	*gzip.Writer
+}
+
+// NewGzipWriter creates a GzipWriter compressing at level, one of the gzip package's levels.
+// The destination is set with Reset, typically by StackWriter.AddWriter.
+func NewGzipWriter(level int) (*GzipWriter, error) {
+	w, err := gzip.NewWriterLevel(nil, level)
+	if err != nil {
+		return nil, err
+	}
+	return &GzipWriter{Writer: w}, nil
+}
+
+// Reset discards any pending state and starts a new gzip stream on w.
+func (w *GzipWriter) Reset(dst io.Writer) error {
+	w.Writer.Reset(dst)
+	return nil
+}
+
+// GzipReader decompresses a gzip stream. Multistream mode is on, as in gzip.Reader.
+type GzipReader struct {
+	gzip.Reader
+	ready bool // whether the last Reset succeeded
+}
+
+// NewGzipReader creates a GzipReader. The source is set with Reset, typically by
+// MultiReader.AddReader, which reads the gzip header.
+func NewGzipReader() *GzipReader {
+	return &GzipReader{}
+}
+
+// Read reads decompressed data. It returns io.EOF until Reset has succeeded.
+func (r *GzipReader) Read(p []byte) (int, error) {
+	if !r.ready {
+		return 0, io.EOF
+	}
+	return r.Reader.Read(p)
+}
+
+// Reset reads the gzip header from src and starts decompressing it.
+func (r *GzipReader) Reset(src io.Reader) error {
+	err := r.Reader.Reset(src)
+	r.ready = err == nil
+	return err
+}
+
+// Close releases the decompressor. The source is not closed.
+func (r *GzipReader) Close() error {
+	if !r.ready {
+		return nil
+	}
+	return r.Reader.Close()
+}
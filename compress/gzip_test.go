@@ -0,0 +1,24 @@
+package compress
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGzipReaderBeforeReset(t *testing.T) {
+	zr := NewGzipReader()
+	if n, err := zr.Read(make([]byte, 8)); n != 0 || err != io.EOF {
+		t.Fatalf("Read before Reset = %d, %v, want 0, EOF", n, err)
+	}
+	if err := zr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zr.Reset(strings.NewReader("not gzip")); err == nil {
+		t.Fatal("Reset accepted a non-gzip source")
+	}
+	if _, err := zr.Read(make([]byte, 8)); err != io.EOF {
+		t.Fatalf("Read after a failed Reset = %v, want EOF", err)
+	}
+}
@@ -0,0 +1,443 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*ParallelGzipWriter)(nil)
+	_ iochain.Flusher          = (*ParallelGzipWriter)(nil)
+	_ iochain.ResettableReader = (*ParallelGzipReader)(nil)
+)
+
+// DefaultParallelBlockSize is the block size used by NewParallelGzipWriter when none is given.
+const DefaultParallelBlockSize = 1 << 20
+
+// maxParallelBlockSize keeps compressed members within the 32-bit size field.
+const maxParallelBlockSize = 1 << 30
+
+// maxParallelMemberSize bounds the recorded size of a member read by ParallelGzipReader:
+// a full block stored uncompressed plus the deflate block headers and gzip framing.
+const maxParallelMemberSize = maxParallelBlockSize + maxParallelBlockSize>>10 + 1<<10
+
+// errParallelMemberSize is returned for a member that exceeds the limits of
+// ParallelGzipWriter, which a well-formed stream never does.
+var errParallelMemberSize = errors.New("compress: parallel gzip member too large")
+
+// parallelExtra is the gzip header extra field written by ParallelGzipWriter: one "IC"
+// subfield holding the compressed size of the member as a little-endian uint32.
+var parallelExtra = []byte{'I', 'C', 4, 0, 0, 0, 0, 0}
+
+// parallelSizeOffset is where the member size sits in a member written by
+// ParallelGzipWriter: the 10-byte fixed header, XLEN and the subfield header.
+const parallelSizeOffset = 10 + 2 + 4
+
+func init() {
+	iochain.RegisterFormat("pgzip", iochain.Format{
+		Keys: []string{"level", "block", "workers"},
+		NewReader: func(opts iochain.Options) (iochain.ResettableReader, error) {
+			workers, err := opts.Int("workers", 0)
+			if err != nil {
+				return nil, err
+			}
+			return NewParallelGzipReader(workers), nil
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			level, err := opts.Int("level", gzip.DefaultCompression)
+			if err != nil {
+				return nil, err
+			}
+			block, err := opts.Int("block", DefaultParallelBlockSize)
+			if err != nil {
+				return nil, err
+			}
+			workers, err := opts.Int("workers", 0)
+			if err != nil {
+				return nil, err
+			}
+			return NewParallelGzipWriter(level, block, workers)
+		},
+	})
+}
+
+// parallelBlock is the result of compressing or decompressing one block.
+type parallelBlock struct {
+	data []byte
+	err  error
+}
+
+// ParallelGzipWriter splits its input into blocks and compresses them concurrently,
+// each as an independent gzip member, writing the members downstream in order. The
+// output is a valid multi-member gzip stream that any gzip reader can decompress;
+// ParallelGzipReader also uses the member sizes recorded in the headers to decompress
+// it concurrently.
+//
+// Flush compresses the pending partial block and waits for all blocks to be written,
+// so frequent flushes produce small blocks and reduce both ratio and parallelism.
+type ParallelGzipWriter struct {
+	level     int
+	blockSize int
+	workers   int
+	gzPool    sync.Pool
+
+	w       io.Writer
+	buf     []byte
+	pending []chan parallelBlock // blocks being compressed, in output order
+	wrote   bool                 // whether a member was written since Reset
+	err     error
+}
+
+// NewParallelGzipWriter creates a ParallelGzipWriter compressing blocks of blockSize
+// bytes at level on up to workers goroutines. A blockSize of 0 means
+// DefaultParallelBlockSize and workers of 0 means GOMAXPROCS. The destination is set
+// with Reset, typically by StackWriter.AddWriter.
+func NewParallelGzipWriter(level, blockSize, workers int) (*ParallelGzipWriter, error) {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+	if blockSize <= 0 {
+		blockSize = DefaultParallelBlockSize
+	}
+	if blockSize > maxParallelBlockSize {
+		return nil, errors.New("compress: parallel block size too large")
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &ParallelGzipWriter{level: level, blockSize: blockSize, workers: workers}, nil
+}
+
+// Write buffers p and hands every full block to a worker. Once all workers are busy,
+// it waits for the oldest block and writes it downstream.
+func (z *ParallelGzipWriter) Write(p []byte) (int, error) {
+	if z.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if z.buf == nil {
+			z.buf = make([]byte, 0, z.blockSize)
+		}
+		n := min(len(p), z.blockSize-len(z.buf))
+		z.buf = append(z.buf, p[:n]...)
+		p = p[n:]
+		if len(z.buf) == z.blockSize {
+			if err := z.dispatch(); err != nil {
+				return written, err
+			}
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Flush compresses the partial block and writes every pending block downstream.
+func (z *ParallelGzipWriter) Flush() error {
+	if z.w == nil || z.err != nil {
+		return z.err
+	}
+	if len(z.buf) > 0 {
+		if err := z.dispatch(); err != nil {
+			return err
+		}
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset discards pending blocks and starts a new stream on dst.
+func (z *ParallelGzipWriter) Reset(dst io.Writer) error {
+	// Workers deliver into buffered channels, so abandoned blocks do not block them.
+	z.pending = nil
+	z.w = dst
+	z.buf = z.buf[:0]
+	z.wrote = false
+	z.err = nil
+	return nil
+}
+
+// Close flushes the remaining blocks. A stream with no data gets one empty member so
+// that it is still valid gzip. The destination is not closed.
+func (z *ParallelGzipWriter) Close() error {
+	if err := z.Flush(); err != nil || z.w == nil || z.wrote {
+		return err
+	}
+	z.buf = z.buf[:0]
+	if err := z.dispatch(); err != nil {
+		return err
+	}
+	return z.writeOldest()
+}
+
+// dispatch hands the buffered block to a worker, first making room if all are busy.
+func (z *ParallelGzipWriter) dispatch() error {
+	if len(z.pending) == z.workers {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	block := z.buf
+	z.buf = nil
+	done := make(chan parallelBlock, 1)
+	go func() {
+		data, err := z.compress(block)
+		done <- parallelBlock{data: data, err: err}
+	}()
+	z.pending = append(z.pending, done)
+	return nil
+}
+
+// writeOldest waits for the oldest pending block and writes it downstream.
+func (z *ParallelGzipWriter) writeOldest() error {
+	b := <-z.pending[0]
+	z.pending = z.pending[1:]
+	if b.err != nil {
+		z.err = b.err
+		return b.err
+	}
+
+	n, err := z.w.Write(b.data)
+	if err == nil && n < len(b.data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		z.err = err
+		return err
+	}
+	z.wrote = true
+	return nil
+}
+
+// compress returns block as a gzip member with its size recorded in the header.
+func (z *ParallelGzipWriter) compress(block []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(block)/2 + 64)
+
+	gw, _ := z.gzPool.Get().(*gzip.Writer)
+	if gw == nil {
+		gw, _ = gzip.NewWriterLevel(&out, z.level)
+	} else {
+		gw.Reset(&out)
+	}
+	defer z.gzPool.Put(gw)
+
+	gw.Header.Extra = parallelExtra
+	if _, err := gw.Write(block); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	data := out.Bytes()
+	binary.LittleEndian.PutUint32(data[parallelSizeOffset:], uint32(len(data)))
+	return data, nil
+}
+
+// ParallelGzipReader decompresses gzip streams written by ParallelGzipWriter,
+// decompressing up to workers members concurrently ahead of the reader. Members
+// without a recorded size, as written by other gzip writers, are decompressed
+// sequentially from there on.
+type ParallelGzipReader struct {
+	workers int
+
+	src      io.Reader
+	pending  []chan parallelBlock // members being decompressed, in order
+	cur      []byte               // decompressed data not yet returned
+	srcErr   error                // error that stopped reading members from src
+	fallback io.Reader            // sequential decoder for the rest of src
+	started  bool                 // whether a member was read from src since Reset
+	err      error
+}
+
+// NewParallelGzipReader creates a ParallelGzipReader using up to workers goroutines,
+// or GOMAXPROCS if workers is 0. The source is set with Reset, typically by
+// MultiReader.AddReader.
+func NewParallelGzipReader(workers int) *ParallelGzipReader {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &ParallelGzipReader{workers: workers}
+}
+
+// Read reads decompressed data.
+func (z *ParallelGzipReader) Read(p []byte) (int, error) {
+	if z.src == nil {
+		return 0, io.EOF
+	}
+
+	for len(z.cur) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.fill()
+		if len(z.pending) == 0 {
+			if z.fallback != nil {
+				return z.fallback.Read(p)
+			}
+			z.err = z.srcErr
+			continue
+		}
+
+		b := <-z.pending[0]
+		z.pending = z.pending[1:]
+		if b.err != nil {
+			z.err = b.err
+			continue
+		}
+		z.cur = b.data
+	}
+
+	n := copy(p, z.cur)
+	z.cur = z.cur[n:]
+	return n, nil
+}
+
+// Reset discards pending members and starts decompressing src.
+func (z *ParallelGzipReader) Reset(src io.Reader) error {
+	z.src = src
+	z.pending = nil
+	z.cur = nil
+	z.srcErr = nil
+	z.fallback = nil
+	z.started = false
+	z.err = nil
+	return nil
+}
+
+// Close discards pending members. The source is not closed.
+func (z *ParallelGzipReader) Close() error {
+	z.pending = nil
+	z.cur = nil
+	return nil
+}
+
+// fill starts decompressing members until all workers are busy or src is exhausted.
+func (z *ParallelGzipReader) fill() {
+	for len(z.pending) < z.workers && z.srcErr == nil && z.fallback == nil {
+		member, head, err := z.readMember()
+		if err != nil {
+			z.srcErr = err
+			return
+		}
+		if head != nil {
+			fr, err := gzip.NewReader(io.MultiReader(bytes.NewReader(head), z.src))
+			if err != nil {
+				z.srcErr = err
+				return
+			}
+			z.fallback = fr
+			return
+		}
+
+		done := make(chan parallelBlock, 1)
+		go func() {
+			data, err := decompressMember(member)
+			done <- parallelBlock{data: data, err: err}
+		}()
+		z.pending = append(z.pending, done)
+	}
+}
+
+// readMember reads the next member from src. If the member has no recorded size, it
+// returns the header bytes read so far in head instead. It returns io.EOF at the end
+// of src, or io.ErrUnexpectedEOF if src holds no member at all.
+func (z *ParallelGzipReader) readMember() (member, head []byte, err error) {
+	head = make([]byte, 10, 12)
+	if _, err := io.ReadFull(z.src, head); err != nil {
+		if err == io.EOF && z.started {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, unexpectedEOF(err)
+	}
+	z.started = true
+	if head[0] != 0x1f || head[1] != 0x8b || head[3]&0x04 == 0 {
+		return nil, head, nil
+	}
+
+	head = head[:12]
+	if _, err := io.ReadFull(z.src, head[10:]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+	xlen := int(binary.LittleEndian.Uint16(head[10:]))
+	head = append(head, make([]byte, xlen)...)
+	if _, err := io.ReadFull(z.src, head[12:]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+
+	size, ok := memberSize(head[12:])
+	if !ok {
+		return nil, head, nil
+	}
+	if size < len(head)+8 {
+		return nil, nil, gzip.ErrHeader
+	}
+	if size > maxParallelMemberSize {
+		return nil, nil, errParallelMemberSize
+	}
+	// The buffer grows with the data that arrives rather than trusting the declared size.
+	buf := bytes.NewBuffer(head)
+	rest := int64(size - len(head))
+	n, err := buf.ReadFrom(io.LimitReader(z.src, rest))
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < rest {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil, nil
+}
+
+// memberSize finds the size recorded by ParallelGzipWriter in a gzip extra field.
+func memberSize(extra []byte) (int, bool) {
+	for len(extra) >= 4 {
+		n := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+n {
+			return 0, false
+		}
+		if extra[0] == 'I' && extra[1] == 'C' && n == 4 {
+			return int(binary.LittleEndian.Uint32(extra[4:])), true
+		}
+		extra = extra[4+n:]
+	}
+	return 0, false
+}
+
+// decompressMember decompresses one member, which may not expand beyond
+// maxParallelBlockSize.
+func decompressMember(member []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(member))
+	if err != nil {
+		return nil, err
+	}
+	zr.Multistream(false)
+	data, err := io.ReadAll(io.LimitReader(zr, maxParallelBlockSize+1))
+	if err == nil && len(data) > maxParallelBlockSize {
+		err = errParallelMemberSize
+	}
+	return data, err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
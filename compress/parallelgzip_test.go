@@ -0,0 +1,79 @@
+package compress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func parallelGzip(t *testing.T, data []byte, blockSize int) []byte {
+	t.Helper()
+	zw, err := NewParallelGzipWriter(-1, blockSize, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := zw.Reset(&out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func parallelGunzip(src []byte) ([]byte, error) {
+	zr := NewParallelGzipReader(4)
+	if err := zr.Reset(bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func TestParallelGzipRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("parallel gzip "), 10000)
+	got, err := parallelGunzip(parallelGzip(t, data, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("round trip returned %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestParallelGzipReaderRejectsEmptySource(t *testing.T) {
+	if _, err := parallelGunzip(nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestParallelGzipReaderRejectsOversizedMember(t *testing.T) {
+	src := parallelGzip(t, []byte("data"), 0)
+	binary.LittleEndian.PutUint32(src[parallelSizeOffset:], maxParallelMemberSize+1)
+	if _, err := parallelGunzip(src); !errors.Is(err, errParallelMemberSize) {
+		t.Fatalf("err = %v, want %v", err, errParallelMemberSize)
+	}
+}
+
+func TestParallelGzipReaderTruncatedLargeMember(t *testing.T) {
+	src := parallelGzip(t, []byte("data"), 0)
+	binary.LittleEndian.PutUint32(src[parallelSizeOffset:], maxParallelMemberSize)
+	if _, err := parallelGunzip(src); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// The declared size of about 1 GiB must not be allocated up front.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	parallelGunzip(src)
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 16<<20 {
+		t.Fatalf("reading a truncated member allocated %d bytes", alloc)
+	}
+}
@@ -0,0 +1,90 @@
+package compress
+
+import (
+	"compress/zlib"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*ZlibWriter)(nil)
+	_ iochain.Flusher          = (*ZlibWriter)(nil)
+	_ iochain.ResettableReader = (*ZlibReader)(nil)
+)
+
+func init() {
+	iochain.RegisterFormat("zlib", iochain.Format{
+		Keys: []string{"level"},
+		NewReader: func(iochain.Options) (iochain.ResettableReader, error) {
+			return NewZlibReader(), nil
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			level, err := opts.Int("level", zlib.DefaultCompression)
+			if err != nil {
+				return nil, err
+			}
+			return NewZlibWriter(level)
+		},
+	})
+}
+
+// ZlibWriter is a zlib.Writer whose Reset returns an error, as iochain.ResettableWriter requires.
+type ZlibWriter struct {
+	*zlib.Writer
+}
+
+// NewZlibWriter creates a ZlibWriter compressing at level, one of the zlib package's levels.
+// The destination is set with Reset, typically by StackWriter.AddWriter.
+func NewZlibWriter(level int) (*ZlibWriter, error) {
+	w, err := zlib.NewWriterLevel(nil, level)
+	if err != nil {
+		return nil, err
+	}
+	return &ZlibWriter{Writer: w}, nil
+}
+
+// Reset discards any pending state and starts a new zlib stream on dst.
+func (w *ZlibWriter) Reset(dst io.Writer) error {
+	w.Writer.Reset(dst)
+	return nil
+}
+
+// ZlibReader decompresses a zlib stream.
+type ZlibReader struct {
+	rc io.ReadCloser
+}
+
+// NewZlibReader creates a ZlibReader. The source is set with Reset, typically by
+// MultiReader.AddReader, which reads the zlib header.
+func NewZlibReader() *ZlibReader {
+	return &ZlibReader{}
+}
+
+func (r *ZlibReader) Read(p []byte) (int, error) {
+	if r.rc == nil {
+		return 0, io.EOF
+	}
+	return r.rc.Read(p)
+}
+
+// Reset reads the zlib header from src and starts decompressing it.
+func (r *ZlibReader) Reset(src io.Reader) error {
+	if r.rc == nil {
+		rc, err := zlib.NewReader(src)
+		if err != nil {
+			return err
+		}
+		r.rc = rc
+		return nil
+	}
+	return r.rc.(zlib.Resetter).Reset(src, nil)
+}
+
+// Close releases the decompressor. The source is not closed.
+func (r *ZlibReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	return r.rc.Close()
+}
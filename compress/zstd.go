@@ -0,0 +1,105 @@
+//go:build zstd
+
+package compress
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	_ iochain.ResettableWriter = (*ZstdWriter)(nil)
+	_ iochain.Flusher          = (*ZstdWriter)(nil)
+	_ iochain.ResettableReader = (*ZstdReader)(nil)
+)
+
+func init() {
+	newZstdDecoder = func(r io.Reader) (io.ReadCloser, error) {
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	}
+	iochain.RegisterFormat("zstd", iochain.Format{
+		Keys: []string{"level"},
+		NewReader: func(iochain.Options) (iochain.ResettableReader, error) {
+			return NewZstdReader()
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			level, err := opts.Int("level", 3)
+			if err != nil {
+				return nil, err
+			}
+			return NewZstdWriter(level)
+		},
+	})
+}
+
+// ZstdWriter compresses with zstd.
+type ZstdWriter struct {
+	enc *zstd.Encoder
+}
+
+// NewZstdWriter creates a ZstdWriter compressing at level, on the zstd command's 1-22 scale.
+// The destination is set with Reset, typically by StackWriter.AddWriter.
+func NewZstdWriter(level int) (*ZstdWriter, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdWriter{enc: enc}, nil
+}
+
+func (w *ZstdWriter) Write(p []byte) (int, error) {
+	return w.enc.Write(p)
+}
+
+// Reset discards any pending state and starts a new zstd frame on dst.
+func (w *ZstdWriter) Reset(dst io.Writer) error {
+	w.enc.Reset(dst)
+	return nil
+}
+
+// Flush writes the pending data as a complete block.
+func (w *ZstdWriter) Flush() error {
+	return w.enc.Flush()
+}
+
+// Close finishes the zstd frame. The destination is not closed.
+func (w *ZstdWriter) Close() error {
+	return w.enc.Close()
+}
+
+// ZstdReader decompresses zstd frames.
+type ZstdReader struct {
+	dec *zstd.Decoder
+}
+
+// NewZstdReader creates a ZstdReader. The source is set with Reset, typically by
+// MultiReader.AddReader. Decoding runs synchronously on the reading goroutine.
+func NewZstdReader() (*ZstdReader, error) {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdReader{dec: dec}, nil
+}
+
+func (r *ZstdReader) Read(p []byte) (int, error) {
+	return r.dec.Read(p)
+}
+
+// Reset starts decompressing src.
+func (r *ZstdReader) Reset(src io.Reader) error {
+	return r.dec.Reset(src)
+}
+
+// Close releases the decoder. The source is not closed, and the reader cannot be
+// reused afterwards.
+func (r *ZstdReader) Close() error {
+	r.dec.Close()
+	return nil
+}
@@ -0,0 +1,23 @@
+package iochain
+
+import "errors"
+
+// Copy copies src into dst until io.EOF, then flushes and closes dst and closes src.
+// dst and src are always closed, even when the copy fails. The top reader's io.WriterTo
+// is used when it has one, then the top writer's io.ReaderFrom, then a buffered loop.
+// It returns the number of bytes copied and the copy, flush and close errors, joined in
+// that order.
+func Copy(dst *StackWriter, src *MultiReader) (int64, error) {
+	if dst == nil || src == nil {
+		return 0, errors.New("copy endpoints cannot be nil")
+	}
+
+	var n int64
+	var err error
+	if src.topIsWriterTo() {
+		n, err = src.WriteTo(dst)
+	} else {
+		n, err = dst.ReadFrom(src)
+	}
+	return n, errors.Join(err, dst.FlushAndClose(), src.Close())
+}
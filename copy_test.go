@@ -0,0 +1,52 @@
+package iochain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCopyClosesBothChains(t *testing.T) {
+	in, out := &closeCounter{}, &closeCounter{}
+	in.WriteString("copied payload")
+	src, err := NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewStackWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.AddWriter(NewTeeWriter(&closeCounter{})); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Copy(dst, src)
+	if err != nil || n != int64(len("copied payload")) || out.String() != "copied payload" {
+		t.Fatalf("Copy = %d, %v, wrote %q", n, err, out.String())
+	}
+	if in.closed != 1 || out.closed != 1 {
+		t.Fatalf("closed source %d and destination %d times, want 1 and 1", in.closed, out.closed)
+	}
+}
+
+func TestCopyClosesSourceWhenWriteFails(t *testing.T) {
+	in := &closeCounter{}
+	in.WriteString("never written")
+	src, err := NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errWrite := errors.New("write failed")
+	dst, err := NewStackWriter(failWriter{errWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Copy(dst, src)
+	if n != 0 || !errors.Is(err, errWrite) {
+		t.Fatalf("Copy = %d, %v, want 0 and %v", n, err, errWrite)
+	}
+	if in.closed != 1 {
+		t.Fatalf("source closed %d times, want 1", in.closed)
+	}
+}
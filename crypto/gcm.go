@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+	"golang.org/x/crypto/hkdf"
+)
+
+// defaultChunkSize is the chunk size of the "aes-gcm" chain format unless set with chunk=.
+const defaultChunkSize = 64 * 1024
+
+// The "aes-gcm" chain format takes a hex-encoded key, usually from the environment as
+// in "aes-gcm:key=env:BACKUP_KEY", and an optional chunk size.
+func init() {
+	iochain.RegisterFormat("aes-gcm", iochain.Format{
+		Keys: []string{"key", "chunk"},
+		NewReader: func(opts iochain.Options) (iochain.ResettableReader, error) {
+			key, chunkSize, err := gcmOptions(opts)
+			if err != nil {
+				return nil, err
+			}
+			return NewGCMReader(key, chunkSize)
+		},
+		NewWriter: func(opts iochain.Options) (iochain.ResettableWriter, error) {
+			key, chunkSize, err := gcmOptions(opts)
+			if err != nil {
+				return nil, err
+			}
+			return NewGCMWriter(key, chunkSize)
+		},
+	})
+}
+
+func gcmOptions(opts iochain.Options) ([]byte, int, error) {
+	key, err := hex.DecodeString(opts.String("key", ""))
+	if err != nil {
+		return nil, 0, fmt.Errorf("option key: %w", err)
+	}
+	chunkSize, err := opts.Int("chunk", defaultChunkSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return key, chunkSize, nil
+}
+
+// gcmSaltSize is the size of the random salt starting an AES-GCM stream.
+const gcmSaltSize = 32
+
+// gcmInfo binds the subkeys derived by gcmKeys to this stream format.
+var gcmInfo = []byte("iochain aes-gcm stream")
+
+// NewGCMWriter creates a Writer sealing chunks of up to chunkSize bytes with AES-GCM.
+// key must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256. Each stream
+// is encrypted with its own subkey, derived from key with HKDF-SHA256 and a random salt
+// written at the start of the stream, so nonces never repeat across streams.
+func NewGCMWriter(key []byte, chunkSize int) (*Writer, error) {
+	keys, err := gcmKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(keys, gcmSaltSize, chunkSize)
+}
+
+// NewGCMReader creates a Reader opening streams written by NewGCMWriter.
+// chunkSize must match the writer's and bounds the memory used per frame.
+func NewGCMReader(key []byte, chunkSize int) (*Reader, error) {
+	keys, err := gcmKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(keys, gcmSaltSize, chunkSize)
+}
+
+// gcmKeys derives a subkey of the same size as key from the salt in the stream header.
+// Each subkey seals a single stream, so the nonce prefix is all zeros.
+func gcmKeys(key []byte) (streamKeys, error) {
+	if _, err := newGCM(key); err != nil {
+		return nil, err
+	}
+	key = append([]byte(nil), key...)
+	return func(salt []byte) (cipher.AEAD, []byte, error) {
+		subkey := make([]byte, len(key))
+		if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, gcmInfo), subkey); err != nil {
+			return nil, nil, err
+		}
+		aead, err := newGCM(subkey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return aead, make([]byte, aead.NonceSize()-nonceSuffixSize), nil
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// secretboxAEAD adapts NaCl secretbox to cipher.AEAD. Additional data is not supported.
+type secretboxAEAD struct {
+	key [32]byte
+}
+
+func (a *secretboxAEAD) NonceSize() int { return 24 }
+
+func (a *secretboxAEAD) Overhead() int { return secretbox.Overhead }
+
+func (a *secretboxAEAD) Seal(dst, nonce, plaintext, _ []byte) []byte {
+	return secretbox.Seal(dst, plaintext, (*[24]byte)(nonce), &a.key)
+}
+
+func (a *secretboxAEAD) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
+	out, ok := secretbox.Open(dst, ciphertext, (*[24]byte)(nonce), &a.key)
+	if !ok {
+		return nil, errors.New("secretbox: open failed")
+	}
+	return out, nil
+}
+
+// secretboxHeaderSize is the size of the random nonce prefix starting a secretbox stream.
+const secretboxHeaderSize = 24 - nonceSuffixSize
+
+// secretboxKeys uses key for every stream, with the stream header as the nonce prefix.
+func secretboxKeys(key *[32]byte) streamKeys {
+	aead := &secretboxAEAD{key: *key}
+	return func(header []byte) (cipher.AEAD, []byte, error) {
+		return aead, header, nil
+	}
+}
+
+// NewSecretboxWriter creates a Writer sealing chunks of up to chunkSize bytes with NaCl secretbox.
+func NewSecretboxWriter(key *[32]byte, chunkSize int) (*Writer, error) {
+	return newWriter(secretboxKeys(key), secretboxHeaderSize, chunkSize)
+}
+
+// NewSecretboxReader creates a Reader opening streams written by NewSecretboxWriter.
+// chunkSize must match the writer's and bounds the memory used per frame.
+func NewSecretboxReader(key *[32]byte, chunkSize int) (*Reader, error) {
+	return newReader(secretboxKeys(key), secretboxHeaderSize, chunkSize)
+}
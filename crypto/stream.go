@@ -0,0 +1,308 @@
+// Package crypto provides authenticated streaming encryption layers for iochain.
+//
+// A stream starts with a random header, from which the cipher derives the key and nonce
+// prefix of the stream, followed by frames of at most chunkSize plaintext bytes. Each
+// frame is a 4-byte big-endian header holding the ciphertext length, with the top bit
+// marking the final frame, followed by the sealed chunk. The nonce of each frame is the
+// prefix, a 4-byte frame counter and the final flag, so reordered or truncated streams
+// fail authentication. Data after the final frame is rejected with ErrTrailingData.
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/pyxsoft/iochain"
+)
+
+var (
+	ErrAuthentication = errors.New("crypto: message authentication failed")
+	ErrTruncated      = errors.New("crypto: stream truncated")
+	ErrFrameTooLarge  = errors.New("crypto: frame exceeds chunk size")
+	ErrStreamTooLong  = errors.New("crypto: too many frames in stream")
+	ErrInvalidChunk   = errors.New("crypto: chunk size out of range")
+	ErrTrailingData   = errors.New("crypto: data after final frame")
+)
+
+const (
+	frameHeaderSize = 4
+	finalFlag       = 1 << 31
+	maxChunkSize    = 1 << 24
+
+	// Nonces end with a 4-byte counter and a 1-byte final flag; the rest is the stream prefix.
+	nonceSuffixSize = 5
+)
+
+// streamKeys returns the AEAD and nonce prefix of a stream given its random header.
+type streamKeys func(header []byte) (aead cipher.AEAD, prefix []byte, err error)
+
+var (
+	_ iochain.ResettableWriter = (*Writer)(nil)
+	_ iochain.ResettableReader = (*Reader)(nil)
+)
+
+// Writer encrypts its input in authenticated chunks. Close must be called to write the
+// final frame; a stream without it is rejected by Reader.
+type Writer struct {
+	keys      streamKeys
+	chunkSize int
+	w         io.Writer
+
+	header        []byte
+	aead          cipher.AEAD
+	prefix        []byte
+	headerWritten bool
+	counter       uint64
+	buf           []byte
+	out           []byte
+	closed        bool
+	err           error
+}
+
+func newWriter(keys streamKeys, headerSize, chunkSize int) (*Writer, error) {
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return nil, ErrInvalidChunk
+	}
+	w := &Writer{
+		keys:      keys,
+		chunkSize: chunkSize,
+		header:    make([]byte, headerSize),
+		buf:       make([]byte, 0, chunkSize),
+	}
+	if err := w.Reset(nil); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write encrypts p, emitting a frame whenever a full chunk is buffered.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == w.chunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush encrypts any buffered bytes as a short, non-final frame.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed || len(w.buf) == 0 {
+		return nil
+	}
+	return w.seal(false)
+}
+
+// Close writes the remaining bytes as the final frame. The underlying writer is not closed.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+// Reset starts a new stream with a fresh random header on dst.
+func (w *Writer) Reset(dst io.Writer) error {
+	w.w = dst
+	w.headerWritten = false
+	w.counter = 0
+	w.buf = w.buf[:0]
+	w.closed = false
+	if _, w.err = rand.Read(w.header); w.err != nil {
+		return w.err
+	}
+	w.aead, w.prefix, w.err = w.keys(w.header)
+	return w.err
+}
+
+func (w *Writer) seal(final bool) error {
+	if w.counter > math.MaxUint32 {
+		w.err = ErrStreamTooLong
+		return w.err
+	}
+	if !w.headerWritten {
+		if _, err := w.w.Write(w.header); err != nil {
+			w.err = err
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	nonce := makeNonce(w.prefix, w.counter, final)
+	w.out = w.aead.Seal(append(w.out[:0], 0, 0, 0, 0), nonce, w.buf, nil)
+	hdr := uint32(len(w.out) - frameHeaderSize)
+	if final {
+		hdr |= finalFlag
+	}
+	binary.BigEndian.PutUint32(w.out, hdr)
+
+	w.buf = w.buf[:0]
+	w.counter++
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader decrypts and authenticates a stream produced by Writer with the same key.
+type Reader struct {
+	keys      streamKeys
+	chunkSize int
+	src       io.Reader
+
+	header     []byte
+	aead       cipher.AEAD
+	prefix     []byte
+	headerRead bool
+	counter    uint64
+	frame      []byte
+	plainBuf   []byte
+	plain      []byte // unread part of plainBuf
+	done       bool
+	err        error
+}
+
+func newReader(keys streamKeys, headerSize, chunkSize int) (*Reader, error) {
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return nil, ErrInvalidChunk
+	}
+	return &Reader{
+		keys:      keys,
+		chunkSize: chunkSize,
+		header:    make([]byte, headerSize),
+	}, nil
+}
+
+// Read returns decrypted bytes. It returns io.EOF only after an authenticated final frame
+// followed by the end of the source.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			r.err = r.end()
+			continue
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// Reset starts decrypting a new stream from src.
+func (r *Reader) Reset(src io.Reader) error {
+	r.src = src
+	r.headerRead = false
+	r.counter = 0
+	r.plain = nil
+	r.done = false
+	r.err = nil
+	return nil
+}
+
+// next reads, authenticates and decrypts one frame.
+func (r *Reader) next() error {
+	if r.src == nil {
+		return ErrTruncated
+	}
+	if !r.headerRead {
+		if _, err := io.ReadFull(r.src, r.header); err != nil {
+			return truncated(err)
+		}
+		aead, prefix, err := r.keys(r.header)
+		if err != nil {
+			return err
+		}
+		r.aead, r.prefix = aead, prefix
+		r.headerRead = true
+	}
+	if r.counter > math.MaxUint32 {
+		return ErrStreamTooLong
+	}
+
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(r.src, hdr[:]); err != nil {
+		return truncated(err)
+	}
+	v := binary.BigEndian.Uint32(hdr[:])
+	final := v&finalFlag != 0
+	size := int(v &^ finalFlag)
+	if size > r.chunkSize+r.aead.Overhead() {
+		return ErrFrameTooLarge
+	}
+
+	if cap(r.frame) < size {
+		r.frame = make([]byte, size)
+	}
+	r.frame = r.frame[:size]
+	if _, err := io.ReadFull(r.src, r.frame); err != nil {
+		return truncated(err)
+	}
+
+	nonce := makeNonce(r.prefix, r.counter, final)
+	plain, err := r.aead.Open(r.plainBuf[:0], nonce, r.frame, nil)
+	if err != nil {
+		return ErrAuthentication
+	}
+	r.plainBuf = plain
+	r.plain = plain
+	r.counter++
+	r.done = final
+	return nil
+}
+
+// end checks that the source ends after the final frame, returning io.EOF if it does.
+func (r *Reader) end() error {
+	var b [1]byte
+	n, err := io.ReadFull(r.src, b[:])
+	if n > 0 {
+		return ErrTrailingData
+	}
+	return err
+}
+
+func makeNonce(prefix []byte, counter uint64, final bool) []byte {
+	nonce := make([]byte, len(prefix)+nonceSuffixSize)
+	n := copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[n:], uint32(counter))
+	if final {
+		nonce[n+4] = 1
+	}
+	return nonce
+}
+
+// truncated maps an early end of input to ErrTruncated.
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
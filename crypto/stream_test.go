@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testChunkSize = 16
+
+// streamCipher builds the writer and reader of one cipher for the tests.
+type streamCipher struct {
+	name      string
+	header    int // bytes before the first frame
+	overhead  int
+	newWriter func() (*Writer, error)
+	newReader func() (*Reader, error)
+}
+
+var testKey = [32]byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+var streamCiphers = []streamCipher{
+	{
+		name:      "secretbox",
+		header:    secretboxHeaderSize,
+		overhead:  16,
+		newWriter: func() (*Writer, error) { return NewSecretboxWriter(&testKey, testChunkSize) },
+		newReader: func() (*Reader, error) { return NewSecretboxReader(&testKey, testChunkSize) },
+	},
+	{
+		name:      "aes-gcm",
+		header:    gcmSaltSize,
+		overhead:  16,
+		newWriter: func() (*Writer, error) { return NewGCMWriter(testKey[:], testChunkSize) },
+		newReader: func() (*Reader, error) { return NewGCMReader(testKey[:], testChunkSize) },
+	},
+}
+
+func seal(t *testing.T, c streamCipher, plain []byte) []byte {
+	t.Helper()
+	w, err := c.newWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := w.Reset(&out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func open(t *testing.T, c streamCipher, stream []byte) ([]byte, error) {
+	t.Helper()
+	r, err := c.newReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reset(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	for _, c := range streamCiphers {
+		for _, size := range []int{0, 1, testChunkSize, 3*testChunkSize + 5} {
+			plain := bytes.Repeat([]byte{'x'}, size)
+			got, err := open(t, c, seal(t, c, plain))
+			if err != nil {
+				t.Fatalf("%s, %d bytes: %v", c.name, size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("%s, %d bytes: got %d bytes back", c.name, size, len(got))
+			}
+		}
+	}
+}
+
+func TestStreamRejectsTampering(t *testing.T) {
+	plain := []byte("0123456789abcdef0123456789abcdef0123456789")
+	for _, c := range streamCiphers {
+		frame := frameHeaderSize + testChunkSize + c.overhead
+		tests := []struct {
+			name   string
+			tamper func(s []byte) []byte
+			want   error
+		}{
+			{"flipped bit", func(s []byte) []byte {
+				s[c.header+frameHeaderSize] ^= 1
+				return s
+			}, ErrAuthentication},
+			{"reordered frames", func(s []byte) []byte {
+				first := append([]byte(nil), s[c.header:c.header+frame]...)
+				copy(s[c.header:], s[c.header+frame:c.header+2*frame])
+				copy(s[c.header+frame:], first)
+				return s
+			}, ErrAuthentication},
+			{"final frame dropped", func(s []byte) []byte {
+				return s[:c.header+2*frame]
+			}, ErrTruncated},
+			{"cut inside a frame", func(s []byte) []byte {
+				return s[:len(s)-1]
+			}, ErrTruncated},
+			{"frame appended", func(s []byte) []byte {
+				return append(s, s[c.header:c.header+frame]...)
+			}, ErrTrailingData},
+			{"byte appended", func(s []byte) []byte {
+				return append(s, 0)
+			}, ErrTrailingData},
+		}
+		for _, tt := range tests {
+			_, err := open(t, c, tt.tamper(seal(t, c, plain)))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s, %s: err = %v, want %v", c.name, tt.name, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestGCMStreamsUseDistinctKeys(t *testing.T) {
+	c := streamCiphers[1]
+	plain := make([]byte, testChunkSize)
+	a, b := seal(t, c, plain), seal(t, c, plain)
+	if bytes.Equal(a[c.header:], b[c.header:]) {
+		t.Fatal("two streams of the same plaintext produced the same ciphertext")
+	}
+
+	// Frames sealed under one stream's subkey must not open under another stream's salt.
+	copy(b[:c.header], a[:c.header])
+	if _, err := open(t, c, b); !errors.Is(err, ErrAuthentication) {
+		t.Fatalf("err = %v, want %v", err, ErrAuthentication)
+	}
+}
@@ -0,0 +1,146 @@
+package iochain
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrCSVBareQuote        = errors.New("bare \" in non-quoted field")
+	ErrCSVExtraneousQuote  = errors.New("extraneous character after closing quote")
+	ErrCSVUnterminated     = errors.New("unterminated quoted field")
+	ErrCSVInvalidDelimiter = errors.New("invalid field delimiter")
+)
+
+// CSVParseError reports a malformed record with its position in the input.
+type CSVParseError struct {
+	Line  int // 1-based line where the error was detected
+	Field int // 1-based field index within the record
+	Err   error
+}
+
+func (e *CSVParseError) Error() string {
+	return fmt.Sprintf("csv: line %d, field %d: %v", e.Line, e.Field, e.Err)
+}
+
+func (e *CSVParseError) Unwrap() error {
+	return e.Err
+}
+
+// CSVRecordReader parses CSV records incrementally from a stream, typically the top of a
+// MultiReader. Quoted fields may contain delimiters, doubled quotes and newlines.
+// Empty lines are skipped.
+type CSVRecordReader struct {
+	src   *bufio.Reader
+	comma rune
+	line  int
+}
+
+// NewCSVRecordReader creates a CSVRecordReader reading from r with the given field delimiter.
+func NewCSVRecordReader(r io.Reader, comma rune) *CSVRecordReader {
+	return &CSVRecordReader{src: bufio.NewReader(r), comma: comma, line: 1}
+}
+
+const (
+	csvFieldStart = iota
+	csvUnquoted
+	csvQuoted
+	csvQuoteSeen // a quote inside a quoted field: either an escape or the closing quote
+)
+
+// ReadRecord returns the next record. It returns io.EOF when there are no more records.
+func (c *CSVRecordReader) ReadRecord() ([]string, error) {
+	if c.comma == '"' || c.comma == '\r' || c.comma == '\n' || !utf8.ValidRune(c.comma) || c.comma == utf8.RuneError {
+		return nil, ErrCSVInvalidDelimiter
+	}
+
+	var (
+		record []string
+		field  strings.Builder
+		state  = csvFieldStart
+	)
+	fail := func(err error) ([]string, error) {
+		return nil, &CSVParseError{Line: c.line, Field: len(record) + 1, Err: err}
+	}
+	endField := func() {
+		record = append(record, field.String())
+		field.Reset()
+		state = csvFieldStart
+	}
+
+	for {
+		ch, _, err := c.src.ReadRune()
+		if err == io.EOF {
+			switch {
+			case state == csvQuoted:
+				return fail(ErrCSVUnterminated)
+			case state == csvFieldStart && len(record) == 0:
+				return nil, io.EOF
+			}
+			endField()
+			return record, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if ch == '\r' && state != csvQuoted && c.peekNewline() {
+			continue
+		}
+
+		switch state {
+		case csvFieldStart, csvUnquoted:
+			switch {
+			case ch == '"' && state == csvFieldStart:
+				state = csvQuoted
+			case ch == '"':
+				return fail(ErrCSVBareQuote)
+			case ch == c.comma:
+				endField()
+			case ch == '\n':
+				c.line++
+				if state == csvFieldStart && len(record) == 0 {
+					continue // empty line
+				}
+				endField()
+				return record, nil
+			default:
+				field.WriteRune(ch)
+				state = csvUnquoted
+			}
+		case csvQuoted:
+			if ch == '"' {
+				state = csvQuoteSeen
+				continue
+			}
+			if ch == '\n' {
+				c.line++
+			}
+			field.WriteRune(ch)
+		case csvQuoteSeen:
+			switch ch {
+			case '"':
+				field.WriteRune('"')
+				state = csvQuoted
+			case c.comma:
+				endField()
+			case '\n':
+				c.line++
+				endField()
+				return record, nil
+			default:
+				return fail(ErrCSVExtraneousQuote)
+			}
+		}
+	}
+}
+
+// peekNewline reports whether the next rune is '\n', so a preceding '\r' can be dropped.
+func (c *CSVRecordReader) peekNewline() bool {
+	b, err := c.src.Peek(1)
+	return err == nil && b[0] == '\n'
+}
@@ -0,0 +1,56 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCSVRecordReader(t *testing.T) {
+	in := "name;note\r\n\n\"Doe; Jane\";\"said \"\"hi\"\"\nthen left\"\nlast;\n;x"
+	r := NewCSVRecordReader(iotest.OneByteReader(strings.NewReader(in)), ';')
+	want := [][]string{
+		{"name", "note"},
+		{"Doe; Jane", "said \"hi\"\nthen left"},
+		{"last", ""},
+		{"", "x"},
+	}
+	for i, w := range want {
+		rec, err := r.ReadRecord()
+		if err != nil || !reflect.DeepEqual(rec, w) {
+			t.Fatalf("record %d = %q, %v, want %q", i, rec, err, w)
+		}
+	}
+	if _, err := r.ReadRecord(); err != io.EOF {
+		t.Fatalf("ReadRecord after the last record = %v, want io.EOF", err)
+	}
+}
+
+func TestCSVRecordReaderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in          string
+		want        error
+		line, field int
+	}{
+		{"a,b\nc,d\"e\n", ErrCSVBareQuote, 2, 2},
+		{"\"a\"b,c\n", ErrCSVExtraneousQuote, 1, 1},
+		{"a,\"multi\nline", ErrCSVUnterminated, 2, 2},
+	} {
+		r := NewCSVRecordReader(strings.NewReader(tc.in), ',')
+		var err error
+		for err == nil {
+			_, err = r.ReadRecord()
+		}
+		var pe *CSVParseError
+		if !errors.As(err, &pe) || !errors.Is(err, tc.want) || pe.Line != tc.line || pe.Field != tc.field {
+			t.Errorf("%q: error = %v, want %v at line %d, field %d", tc.in, err, tc.want, tc.line, tc.field)
+		}
+	}
+
+	if _, err := NewCSVRecordReader(strings.NewReader("a"), '"').ReadRecord(); err != ErrCSVInvalidDelimiter {
+		t.Errorf("quote delimiter: error = %v, want %v", err, ErrCSVInvalidDelimiter)
+	}
+}
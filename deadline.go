@@ -0,0 +1,60 @@
+package iochain
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// SetWriteDeadline bounds later Write, WriteString and Flush calls. If the base writer
+// supports write deadlines, as a net.Conn does, the deadline is set on it, so a layer
+// blocked on the connection fails with the connection's timeout error. Otherwise a
+// watchdog makes calls return os.ErrDeadlineExceeded once t passes; the blocked call
+// then completes in the background, holding the chain until it does, and a write timed
+// out that way fails the chain with ErrChainFailed, as with WriteContext. A zero t
+// removes the deadline.
+func (m *StackWriter) SetWriteDeadline(t time.Time) error {
+	if d, ok := m.Base().(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	m.writeDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+// SetReadDeadline bounds later Read calls. If the base reader supports read deadlines,
+// as a net.Conn does, the deadline is set on it. Otherwise a watchdog makes Read return
+// os.ErrDeadlineExceeded once t passes, keeping the data of the abandoned read for the
+// next Read as ReadContext does. A zero t removes the deadline.
+func (m *MultiReader) SetReadDeadline(t time.Time) error {
+	if d, ok := m.base.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	m.readDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+func deadlineNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// watchdogContext returns a context expiring at the deadline stored in d, or nil if
+// no deadline is set.
+func watchdogContext(d *atomic.Int64) (context.Context, context.CancelFunc) {
+	ns := d.Load()
+	if ns == 0 {
+		return nil, nil
+	}
+	return context.WithDeadline(context.Background(), time.Unix(0, ns))
+}
+
+// deadlineErr reports an expired watchdog as os.ErrDeadlineExceeded, like a net.Conn.
+func deadlineErr(err error) error {
+	if err == context.DeadlineExceeded {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteDeadlineWatchdog(t *testing.T) {
+	base := newBlockingWriter()
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetWriteDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("stuck")); err != os.ErrDeadlineExceeded {
+		t.Fatalf("Write = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+
+	close(base.release)
+	sw.SetWriteDeadline(time.Time{})
+	if _, err := sw.Write([]byte("after")); err != ErrChainFailed {
+		t.Fatalf("Write after a timed-out write = %v, want %v", err, ErrChainFailed)
+	}
+}
+
+func TestReadDeadlineWatchdog(t *testing.T) {
+	base := newGatedReader("slow data")
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Read(make([]byte, 16)); err != os.ErrDeadlineExceeded {
+		t.Fatalf("Read = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+
+	close(base.release)
+	mr.SetReadDeadline(time.Time{})
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "slow data" {
+		t.Fatalf("ReadAll after the deadline = %q, %v", got, err)
+	}
+}
+
+func TestDeadlinesUseConnection(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	mr, err := NewReader(left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read = %v, want the connection's timeout", err)
+	}
+
+	sw, err := NewStackWriter(left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetWriteDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("x")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Write = %v, want the connection's timeout", err)
+	}
+}
@@ -0,0 +1,117 @@
+package iochain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// DeltaReader reconstructs a stream from DeltaWriter operations and the same reference.
+type DeltaReader struct {
+	src *bufio.Reader
+	ref []byte
+
+	op        byte // operation in progress, 0 if none
+	copyOff   int
+	remaining int
+}
+
+// NewDeltaReader creates a DeltaReader that applies ops against reference.
+// The source is set with Reset, typically by MultiReader.AddReader.
+func NewDeltaReader(reference []byte) *DeltaReader {
+	return &DeltaReader{ref: reference}
+}
+
+// Read returns reconstructed bytes.
+func (d *DeltaReader) Read(p []byte) (int, error) {
+	if d.src == nil {
+		return 0, io.EOF
+	}
+	for d.remaining == 0 {
+		if err := d.nextOp(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := len(p)
+	if n > d.remaining {
+		n = d.remaining
+	}
+	switch d.op {
+	case deltaOpCopy:
+		copy(p, d.ref[d.copyOff:d.copyOff+n])
+		d.copyOff += n
+	case deltaOpInsert:
+		var err error
+		n, err = d.src.Read(p[:n])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.remaining -= n
+		return n, err
+	}
+	d.remaining -= n
+	return n, nil
+}
+
+// Reset discards any pending operation and starts decoding from src.
+func (d *DeltaReader) Reset(src io.Reader) error {
+	if d.src == nil {
+		d.src = bufio.NewReader(src)
+	} else {
+		d.src.Reset(src)
+	}
+	d.op = 0
+	d.remaining = 0
+	return nil
+}
+
+// nextOp reads the next operation header. It returns io.EOF only at an operation boundary.
+func (d *DeltaReader) nextOp() error {
+	op, err := d.src.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case deltaOpCopy:
+		off, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		length, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		if off > len(d.ref) || length > len(d.ref)-off {
+			return ErrInvalidDelta
+		}
+		d.copyOff = off
+		d.remaining = length
+	case deltaOpInsert:
+		length, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.remaining = length
+	default:
+		return ErrInvalidDelta
+	}
+	d.op = op
+	return nil
+}
+
+func (d *DeltaReader) readLength() (int, error) {
+	v, err := binary.ReadUvarint(d.src)
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxInt {
+		return 0, ErrInvalidDelta
+	}
+	return int(v), nil
+}
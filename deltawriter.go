@@ -0,0 +1,161 @@
+package iochain
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Delta streams are a sequence of operations:
+//
+//	'C' uvarint(offset) uvarint(length)  copy length bytes of the reference starting at offset
+//	'I' uvarint(length) data             insert length literal bytes
+const (
+	deltaOpCopy   = 'C'
+	deltaOpInsert = 'I'
+
+	// deltaBlockSize is the minimum match length; the reference is indexed in blocks of this size.
+	deltaBlockSize = 16
+	// deltaMaxInsert bounds the literal bytes buffered before an insert op is emitted.
+	deltaMaxInsert = 32 * 1024
+)
+
+// ErrInvalidDelta is returned by DeltaReader for malformed delta streams.
+var ErrInvalidDelta = errors.New("invalid delta stream")
+
+// DeltaWriter encodes the written stream as copy/insert operations against a reference.
+// Matching is greedy: any run of at least 16 bytes that also occurs in the reference is
+// emitted as a copy. Close must be called to emit the trailing operations.
+type DeltaWriter struct {
+	w     io.Writer
+	ref   []byte
+	index map[[deltaBlockSize]byte]int
+
+	buf     []byte // input not yet matched
+	lit     []byte // literal bytes for the next insert op
+	copyOff int    // reference offset of the copy op in progress
+	copyLen int    // length of the copy op in progress, 0 if none
+}
+
+// NewDeltaWriter creates a DeltaWriter writing ops against reference to w.
+func NewDeltaWriter(w io.Writer, reference []byte) *DeltaWriter {
+	index := make(map[[deltaBlockSize]byte]int, len(reference)/deltaBlockSize)
+	for off := 0; off+deltaBlockSize <= len(reference); off += deltaBlockSize {
+		key := [deltaBlockSize]byte(reference[off : off+deltaBlockSize])
+		if _, ok := index[key]; !ok {
+			index[key] = off
+		}
+	}
+	return &DeltaWriter{w: w, ref: reference, index: index}
+}
+
+// Write buffers p and emits every operation that can no longer be extended. p is
+// always accepted, so the count is len(p) even when emitting an operation fails.
+func (d *DeltaWriter) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	return len(p), d.encode(false)
+}
+
+// Flush emits all pending operations. Matches spanning a Flush are split in two.
+func (d *DeltaWriter) Flush() error {
+	return d.encode(true)
+}
+
+// Close emits all pending operations. The underlying writer is not closed.
+func (d *DeltaWriter) Close() error {
+	return d.encode(true)
+}
+
+// Reset discards pending state and starts a new delta stream on w.
+func (d *DeltaWriter) Reset(w io.Writer) error {
+	d.w = w
+	d.buf = d.buf[:0]
+	d.lit = d.lit[:0]
+	d.copyLen = 0
+	return nil
+}
+
+// encode consumes d.buf. Unless final is set, a trailing copy that may still grow and
+// trailing bytes too short to start a match are kept for the next call. The consumed
+// bytes are removed from d.buf even if emitting an operation fails.
+func (d *DeltaWriter) encode(final bool) error {
+	i := 0
+	defer func() {
+		d.buf = append(d.buf[:0], d.buf[i:]...)
+	}()
+	for {
+		if d.copyLen > 0 {
+			end := d.copyOff + d.copyLen
+			for i < len(d.buf) && end < len(d.ref) && d.buf[i] == d.ref[end] {
+				i++
+				end++
+			}
+			d.copyLen = end - d.copyOff
+			if i == len(d.buf) && end < len(d.ref) && !final {
+				break
+			}
+			if err := d.emitCopy(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(d.buf)-i < deltaBlockSize {
+			if final {
+				d.lit = append(d.lit, d.buf[i:]...)
+				i = len(d.buf)
+			}
+			break
+		}
+
+		if off, ok := d.index[[deltaBlockSize]byte(d.buf[i:i+deltaBlockSize])]; ok {
+			// Extend the match backwards into the pending literals.
+			for len(d.lit) > 0 && off > 0 && d.lit[len(d.lit)-1] == d.ref[off-1] {
+				d.lit = d.lit[:len(d.lit)-1]
+				off--
+				d.copyLen++
+			}
+			d.copyOff = off
+			d.copyLen += deltaBlockSize
+			i += deltaBlockSize
+			if err := d.emitInsert(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		d.lit = append(d.lit, d.buf[i])
+		i++
+		if len(d.lit) >= deltaMaxInsert {
+			if err := d.emitInsert(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if final {
+		return d.emitInsert()
+	}
+	return nil
+}
+
+func (d *DeltaWriter) emitCopy() error {
+	hdr := []byte{deltaOpCopy}
+	hdr = binary.AppendUvarint(hdr, uint64(d.copyOff))
+	hdr = binary.AppendUvarint(hdr, uint64(d.copyLen))
+	d.copyLen = 0
+	_, err := d.w.Write(hdr)
+	return err
+}
+
+func (d *DeltaWriter) emitInsert() error {
+	if len(d.lit) == 0 {
+		return nil
+	}
+	op := []byte{deltaOpInsert}
+	op = binary.AppendUvarint(op, uint64(len(d.lit)))
+	op = append(op, d.lit...)
+	d.lit = d.lit[:0]
+	_, err := d.w.Write(op)
+	return err
+}
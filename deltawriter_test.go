@@ -0,0 +1,64 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	ref := []byte(strings.Repeat("reference block ", 64))
+	data := append(append([]byte("new head "), ref[100:700]...), "new tail"...)
+
+	var delta bytes.Buffer
+	dw := NewDeltaWriter(&delta, ref)
+	if _, err := dw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if delta.Len() >= len(data) {
+		t.Fatalf("delta is %d bytes for %d bytes of input", delta.Len(), len(data))
+	}
+
+	dr := NewDeltaReader(ref)
+	if err := dr.Reset(&delta); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(dr)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+}
+
+// failOnceWriter fails its first Write.
+type failOnceWriter struct {
+	err    error
+	failed bool
+}
+
+func (f *failOnceWriter) Write(p []byte) (int, error) {
+	if !f.failed {
+		f.failed = true
+		return 0, f.err
+	}
+	return len(p), nil
+}
+
+func TestDeltaWriterEmitFailure(t *testing.T) {
+	w := &failOnceWriter{err: errors.New("downstream failed")}
+	dw := NewDeltaWriter(w, nil)
+
+	// Without a reference every byte is a literal, emitted once deltaMaxInsert are pending.
+	p := make([]byte, deltaMaxInsert+100)
+	n, err := dw.Write(p)
+	if n != len(p) || !errors.Is(err, w.err) {
+		t.Fatalf("Write = %d, %v, want %d, %v", n, err, len(p), w.err)
+	}
+	if want := len(p) - deltaMaxInsert; len(dw.buf) != want {
+		t.Fatalf("%d bytes left in the buffer after a failed emit, want the %d not yet encoded", len(dw.buf), want)
+	}
+}
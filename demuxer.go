@@ -0,0 +1,106 @@
+package iochain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// Demuxer splits a stream written by Muxer back into its logical streams. It reads
+// frames on demand: reading one stream buffers in memory the frames of other streams
+// met on the way, until they are read.
+type Demuxer struct {
+	mu      sync.Mutex
+	src     *bufio.Reader
+	pending map[uint64][]byte
+	ended   map[uint64]bool
+	err     error // sticky error of the underlying reader
+}
+
+// NewDemuxer creates a Demuxer reading frames from r.
+func NewDemuxer(r io.Reader) *Demuxer {
+	return &Demuxer{
+		src:     bufio.NewReader(r),
+		pending: make(map[uint64][]byte),
+		ended:   make(map[uint64]bool),
+	}
+}
+
+// Stream returns a reader for the logical stream id. It returns io.EOF after the stream's
+// end-of-stream frame, and io.ErrUnexpectedEOF if the input ends before it.
+func (d *Demuxer) Stream(id uint64) io.Reader {
+	return &demuxStream{demux: d, id: id}
+}
+
+// read fills p with data of stream id, reading frames until some is available.
+func (d *Demuxer) read(id uint64, p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		if buf := d.pending[id]; len(buf) > 0 {
+			n := copy(p, buf)
+			d.pending[id] = buf[n:]
+			return n, nil
+		}
+		if d.ended[id] {
+			return 0, io.EOF
+		}
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.readFrame()
+	}
+}
+
+// readFrame reads one frame into the pending buffers.
+func (d *Demuxer) readFrame() error {
+	id, err := binary.ReadUvarint(d.src)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	length, err := binary.ReadUvarint(d.src)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if length > maxMuxFrame {
+		return ErrInvalidMuxFrame
+	}
+	if length == 0 {
+		d.ended[id] = true
+		return nil
+	}
+
+	buf := d.pending[id]
+	start := len(buf)
+	buf = append(buf, make([]byte, length)...)
+	if _, err := io.ReadFull(d.src, buf[start:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	d.pending[id] = buf
+	return nil
+}
+
+// unexpectedEOF turns io.EOF inside a frame into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type demuxStream struct {
+	demux *Demuxer
+	id    uint64
+}
+
+func (s *demuxStream) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return s.demux.read(s.id, p)
+}
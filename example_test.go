@@ -0,0 +1,26 @@
+package iochain_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/pyxsoft/iochain"
+)
+
+func ExampleWrapWriter() {
+	var buf bytes.Buffer
+	sw, _ := iochain.NewStackWriter(&buf)
+	_ = sw.AddWriter(iochain.WrapWriter(func(w io.Writer) io.Writer {
+		return gzip.NewWriter(w)
+	}))
+
+	sw.Write([]byte("Hello World!\n"))
+	sw.FlushAndClose()
+
+	zr, _ := gzip.NewReader(&buf)
+	plain, _ := io.ReadAll(zr)
+	fmt.Print(string(plain))
+	// Output: Hello World!
+}
@@ -0,0 +1,143 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// FieldSpillReader splits a stream into delimiter-terminated fields, such as JSON lines.
+// Fields up to the memory threshold are kept in memory; larger fields are spilled to a
+// temporary file so a single huge field cannot exhaust memory.
+//
+// Delim defaults to '\n' and TempDir to os.TempDir(). Spill files are removed when the
+// returned field is closed, or at the latest when the FieldSpillReader is closed.
+type FieldSpillReader struct {
+	src       *bufio.Reader
+	threshold int
+	Delim     byte
+	TempDir   string
+
+	mu     sync.Mutex
+	spills map[*spillFile]struct{}
+}
+
+// NewFieldSpillReader creates a FieldSpillReader reading from r that keeps fields of up
+// to memThreshold bytes in memory.
+func NewFieldSpillReader(r io.Reader, memThreshold int) *FieldSpillReader {
+	return &FieldSpillReader{
+		src:       bufio.NewReader(r),
+		threshold: memThreshold,
+		Delim:     '\n',
+		spills:    make(map[*spillFile]struct{}),
+	}
+}
+
+// NextField returns the next field without its delimiter. The caller must close it.
+// It returns io.EOF when the stream has no more fields.
+func (f *FieldSpillReader) NextField() (io.ReadCloser, error) {
+	var (
+		mem   []byte
+		spill *os.File
+		seen  bool
+	)
+	for {
+		chunk, err := f.src.ReadSlice(f.Delim)
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			f.discard(spill)
+			return nil, err
+		}
+		seen = seen || len(chunk) > 0
+		last := err != bufio.ErrBufferFull
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+
+		if spill == nil && len(mem)+len(chunk) > f.threshold {
+			if spill, err = os.CreateTemp(f.TempDir, "iochain-field-*"); err != nil {
+				return nil, err
+			}
+			if _, err := spill.Write(mem); err != nil {
+				f.discard(spill)
+				return nil, err
+			}
+			mem = nil
+		}
+		if spill != nil {
+			if _, err := spill.Write(chunk); err != nil {
+				f.discard(spill)
+				return nil, err
+			}
+		} else {
+			mem = append(mem, chunk...)
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if !seen {
+		return nil, io.EOF
+	}
+	if spill == nil {
+		return io.NopCloser(bytes.NewReader(mem)), nil
+	}
+	if _, err := spill.Seek(0, io.SeekStart); err != nil {
+		f.discard(spill)
+		return nil, err
+	}
+
+	sf := &spillFile{File: spill, owner: f}
+	f.mu.Lock()
+	f.spills[sf] = struct{}{}
+	f.mu.Unlock()
+	return sf, nil
+}
+
+// Close removes any spill files whose fields have not been closed yet.
+// The underlying reader is not closed.
+func (f *FieldSpillReader) Close() error {
+	f.mu.Lock()
+	spills := f.spills
+	f.spills = make(map[*spillFile]struct{})
+	f.mu.Unlock()
+
+	var errs []error
+	for sf := range spills {
+		errs = append(errs, sf.remove())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FieldSpillReader) discard(spill *os.File) {
+	if spill != nil {
+		_ = spill.Close()
+		_ = os.Remove(spill.Name())
+	}
+}
+
+// spillFile is a field stored in a temporary file that is removed on Close.
+type spillFile struct {
+	*os.File
+	owner *FieldSpillReader
+	once  sync.Once
+	err   error
+}
+
+func (s *spillFile) Close() error {
+	s.owner.mu.Lock()
+	delete(s.owner.spills, s)
+	s.owner.mu.Unlock()
+	return s.remove()
+}
+
+func (s *spillFile) remove() error {
+	s.once.Do(func() {
+		s.err = errors.Join(s.File.Close(), os.Remove(s.File.Name()))
+	})
+	return s.err
+}
@@ -0,0 +1,62 @@
+package iochain
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFieldSpillReader(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("b", 10000)
+	f := NewFieldSpillReader(strings.NewReader("small\n\n"+big+"\n"+big), 64)
+	f.TempDir = dir
+
+	spillCount := func() int {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+	next := func(want string, spilled bool) io.ReadCloser {
+		t.Helper()
+		field, err := f.NextField()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := field.(*spillFile); ok != spilled {
+			t.Fatalf("field of %d bytes spilled = %v, want %v", len(want), ok, spilled)
+		}
+		got, err := io.ReadAll(field)
+		if err != nil || string(got) != want {
+			t.Fatalf("field = %d bytes, %v, want %d bytes", len(got), err, len(want))
+		}
+		return field
+	}
+
+	next("small", false).Close()
+	next("", false).Close()
+	field := next(big, true)
+	if spillCount() != 1 {
+		t.Fatalf("%d spill files, want 1", spillCount())
+	}
+	if err := field.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if spillCount() != 0 {
+		t.Fatal("closing the field left its spill file behind")
+	}
+
+	next(big, true) // left open for Close to clean up
+	if _, err := f.NextField(); err != io.EOF {
+		t.Fatalf("NextField after the last field = %v, want io.EOF", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if spillCount() != 0 {
+		t.Fatal("Close left an open field's spill file behind")
+	}
+}
@@ -0,0 +1,139 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Fork returns n readers that each receive the full remaining output of the chain, for
+// consumers such as a hash check running alongside the actual processing. The chain is
+// read on demand by whichever fork needs data, through a shared buffer; a fork that is
+// more than maxLag bytes ahead of the slowest open fork waits for it to catch up.
+// Closing a fork unsubscribes it, so a consumer that stops early does not hold the
+// others back. After Fork the chain must only be read through the forks, and it must
+// still be closed by its owner once they are done.
+func (m *MultiReader) Fork(n, maxLag int) ([]io.ReadCloser, error) {
+	if n < 1 {
+		return nil, errors.New("fork count must be positive")
+	}
+	if maxLag < 1 {
+		return nil, errors.New("fork lag must be positive")
+	}
+
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return nil, ErrChainClosed
+	}
+
+	h := &forkHub{src: m, maxLag: maxLag, offsets: make([]int64, n), open: make([]bool, n)}
+	h.cond = sync.NewCond(&h.mu)
+	forks := make([]io.ReadCloser, n)
+	for i := range forks {
+		h.open[i] = true
+		forks[i] = &forkReader{hub: h, id: i}
+	}
+	return forks, nil
+}
+
+// forkHub holds the data read from a forked chain until every open fork has seen it.
+type forkHub struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signalled when data arrives, a fork advances or a fork closes
+	src     io.Reader
+	maxLag  int
+	buf     []byte // stream bytes from offset base on
+	base    int64
+	offsets []int64 // next stream offset of each fork
+	open    []bool
+	reading bool  // whether a fork is reading from src
+	err     error // error that ended src, returned once the buffer is drained
+}
+
+// forkReader is one consumer of a forkHub.
+type forkReader struct {
+	hub *forkHub
+	id  int
+}
+
+func (f *forkReader) Read(p []byte) (int, error) {
+	h := f.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for {
+		if !h.open[f.id] {
+			return 0, io.ErrClosedPipe
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		end := h.base + int64(len(h.buf))
+		if off := h.offsets[f.id]; off < end {
+			n := copy(p, h.buf[off-h.base:])
+			h.offsets[f.id] += int64(n)
+			h.trim()
+			h.cond.Broadcast()
+			return n, nil
+		}
+		if h.err != nil {
+			return 0, h.err
+		}
+
+		room := h.maxLag - int(end-h.slowest())
+		if h.reading || room <= 0 {
+			h.cond.Wait()
+			continue
+		}
+
+		// Read without the lock so that other forks can drain the buffer meanwhile.
+		h.reading = true
+		chunk := make([]byte, min(room, 32*1024))
+		h.mu.Unlock()
+		n, err := h.src.Read(chunk)
+		h.mu.Lock()
+		h.reading = false
+		h.buf = append(h.buf, chunk[:n]...)
+		if err != nil {
+			h.err = err
+		}
+		h.cond.Broadcast()
+	}
+}
+
+// Close unsubscribes the fork. It does not close the chain.
+func (f *forkReader) Close() error {
+	h := f.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.open[f.id] {
+		h.open[f.id] = false
+		h.trim()
+		h.cond.Broadcast()
+	}
+	return nil
+}
+
+// slowest returns the offset of the slowest open fork, or the end of the buffer if all
+// are closed. The lock must be held.
+func (h *forkHub) slowest() int64 {
+	low := h.base + int64(len(h.buf))
+	for i, off := range h.offsets {
+		if h.open[i] && off < low {
+			low = off
+		}
+	}
+	return low
+}
+
+// trim drops the buffered bytes every open fork has read. The lock must be held.
+func (h *forkHub) trim() {
+	if drop := int(h.slowest() - h.base); drop > 0 {
+		h.buf = append(h.buf[:0], h.buf[drop:]...)
+		h.base += int64(drop)
+	}
+}
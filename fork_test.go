@@ -0,0 +1,154 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestForkDeliversFullStream(t *testing.T) {
+	data := strings.Repeat("forked ", 5000)
+	mr, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forks, err := mr.Fork(3, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([][]byte, len(forks))
+	errs := make([]error, len(forks))
+	var wg sync.WaitGroup
+	for i, f := range forks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], errs[i] = io.ReadAll(iotest.HalfReader(f))
+		}()
+	}
+	wg.Wait()
+	for i := range forks {
+		if errs[i] != nil {
+			t.Fatalf("fork %d: %v", i, errs[i])
+		}
+		if string(got[i]) != data {
+			t.Fatalf("fork %d read %d bytes, want %d", i, len(got[i]), len(data))
+		}
+	}
+}
+
+func TestForkBoundsLag(t *testing.T) {
+	mr, err := NewReader(strings.NewReader(strings.Repeat("x", 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forks, err := mr.Fork(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ahead, behind := forks[0], forks[1]
+	if _, err := io.ReadFull(ahead, make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ahead.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Read more than maxLag ahead returned %v, want it to wait", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if _, err := io.ReadFull(behind, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read still waiting after the slow fork caught up")
+	}
+}
+
+func TestForkCloseReleasesOthers(t *testing.T) {
+	data := strings.Repeat("y", 100)
+	mr, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forks, err := mr.Fork(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(forks[0])
+		done <- b
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := forks[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-done:
+		if string(b) != data {
+			t.Fatalf("read %q, want %q", b, data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closed fork still holds the others back")
+	}
+
+	if _, err := forks[1].Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Fatalf("Read on closed fork = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestForkSourceError(t *testing.T) {
+	errCorrupt := errors.New("corrupt")
+	mr, err := NewReader(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errCorrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forks, err := mr.Fork(2, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range forks {
+		var got bytes.Buffer
+		if _, err := got.ReadFrom(f); !errors.Is(err, errCorrupt) {
+			t.Fatalf("fork %d error = %v, want %v", i, err, errCorrupt)
+		}
+		if got.String() != "abc" {
+			t.Fatalf("fork %d read %q, want %q", i, got.String(), "abc")
+		}
+	}
+}
+
+func TestForkInvalid(t *testing.T) {
+	mr, err := NewReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Fork(0, 10); err == nil {
+		t.Fatal("Fork(0, 10) succeeded, want error")
+	}
+	if _, err := mr.Fork(2, 0); err == nil {
+		t.Fatal("Fork(2, 0) succeeded, want error")
+	}
+	mr.Close()
+	if _, err := mr.Fork(2, 10); err != ErrChainClosed {
+		t.Fatalf("Fork after Close = %v, want %v", err, ErrChainClosed)
+	}
+}
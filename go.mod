@@ -1,3 +1,10 @@
 module github.com/pyxsoft/iochain
 
 go 1.22
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.33.0
+)
+
+require golang.org/x/sys v0.30.0 // indirect
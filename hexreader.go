@@ -0,0 +1,34 @@
+package iochain
+
+import (
+	"encoding/hex"
+	"io"
+)
+
+var _ ResettableReader = (*HexReader)(nil)
+
+// HexReader decodes hexadecimal read from its source. Upper- and lowercase digits are
+// accepted; any other byte, including whitespace, is an error.
+type HexReader struct {
+	r io.Reader
+}
+
+// NewHexReader creates a HexReader. The source is set with Reset, typically by
+// MultiReader.AddReader.
+func NewHexReader() *HexReader {
+	return &HexReader{}
+}
+
+// Read reads decoded data.
+func (h *HexReader) Read(p []byte) (int, error) {
+	if h.r == nil {
+		return 0, io.EOF
+	}
+	return h.r.Read(p)
+}
+
+// Reset starts decoding from src.
+func (h *HexReader) Reset(src io.Reader) error {
+	h.r = hex.NewDecoder(src)
+	return nil
+}
@@ -0,0 +1,34 @@
+package iochain
+
+import (
+	"encoding/hex"
+	"io"
+)
+
+var _ ResettableWriter = (*HexWriter)(nil)
+
+// HexWriter writes everything written through it as lowercase hexadecimal.
+// It does not buffer, so there is nothing to flush.
+type HexWriter struct {
+	w io.Writer
+}
+
+// NewHexWriter creates a HexWriter. The downstream writer is set with Reset, typically
+// by StackWriter.AddWriter.
+func NewHexWriter() *HexWriter {
+	return &HexWriter{}
+}
+
+// Write encodes p.
+func (h *HexWriter) Write(p []byte) (int, error) {
+	if h.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return h.w.Write(p)
+}
+
+// Reset changes the downstream writer.
+func (h *HexWriter) Reset(w io.Writer) error {
+	h.w = hex.NewEncoder(w)
+	return nil
+}
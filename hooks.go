@@ -0,0 +1,97 @@
+package iochain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HookEvent describes one call on a layer of a StackWriter or MultiReader.
+type HookEvent struct {
+	Index    int    // position of the layer, from the base at 0
+	Layer    string // type of the layer, e.g. "*gzip.Writer"
+	Bytes    int    // bytes written or read; 0 for Flush and Close
+	Start    time.Time
+	Duration time.Duration // time spent in the call, including the layers it reached
+	Err      error
+}
+
+// Hooks are callbacks invoked after each call on each layer of a chain, for metrics
+// and tracing. Writes are reported for every layer a write passes through, reads for
+// every layer a read is served by. Any callback may be nil. Callbacks run with the
+// chain locked and must not call back into it.
+type Hooks struct {
+	OnWrite func(HookEvent)
+	OnRead  func(HookEvent)
+	OnFlush func(HookEvent)
+	OnClose func(HookEvent)
+}
+
+// SetHooks installs h on the chain. It must be called before any writer is added.
+func (m *StackWriter) SetHooks(h Hooks) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	if len(m.writers) != 1 {
+		return errors.New("hooks must be set before writers are added")
+	}
+	m.hooks = &h
+	return nil
+}
+
+// SetHooks installs h on the chain. It must be called before any reader is added.
+func (m *MultiReader) SetHooks(h Hooks) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	if len(m.readers) != 1 {
+		return errors.New("hooks must be set before readers are added")
+	}
+	m.hooks = &h
+	return nil
+}
+
+// fire calls fn with an event for a call on layer l that started at start.
+func fire(fn func(HookEvent), index int, l any, n int, start time.Time, err error) {
+	if fn == nil {
+		return
+	}
+	fn(HookEvent{
+		Index:    index,
+		Layer:    fmt.Sprintf("%T", l),
+		Bytes:    n,
+		Start:    start,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+func (h *Hooks) write(index int, l any, n int, start time.Time, err error) {
+	if h != nil {
+		fire(h.OnWrite, index, l, n, start, err)
+	}
+}
+
+func (h *Hooks) read(index int, l any, n int, start time.Time, err error) {
+	if h != nil {
+		fire(h.OnRead, index, l, n, start, err)
+	}
+}
+
+func (h *Hooks) flush(index int, l any, start time.Time, err error) {
+	if h != nil {
+		fire(h.OnFlush, index, l, 0, start, err)
+	}
+}
+
+func (h *Hooks) close(index int, l any, start time.Time, err error) {
+	if h != nil {
+		fire(h.OnClose, index, l, 0, start, err)
+	}
+}
@@ -0,0 +1,80 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStackWriterHooks(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(bufio.NewWriter(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var writes, flushes, closes []HookEvent
+	err = sw.SetHooks(Hooks{
+		OnWrite: func(e HookEvent) { writes = append(writes, e) },
+		OnFlush: func(e HookEvent) { flushes = append(flushes, e) },
+		OnClose: func(e HookEvent) { closes = append(closes, e) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewHexWriter()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewTeeWriter(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SetHooks(Hooks{}); err == nil {
+		t.Fatal("SetHooks succeeded after a writer was added")
+	}
+
+	if _, err := sw.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	bytesAt := map[int]int{}
+	for _, e := range writes {
+		bytesAt[e.Index] += e.Bytes
+		if e.Err != nil || e.Duration < 0 {
+			t.Fatalf("write event %+v", e)
+		}
+	}
+	if bytesAt[2] != 2 || bytesAt[1] != 2 || bytesAt[0] != 4 {
+		t.Fatalf("write events %+v, want 2, 2 and 4 bytes from the top down", writes)
+	}
+	if writes[0].Layer != "*bufio.Writer" || writes[len(writes)-1].Layer != "*iochain.TeeWriter" {
+		t.Fatalf("write events %+v, want the base first and the top layer last", writes)
+	}
+
+	// Only the layers implementing Flusher or io.Closer report those calls.
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if len(flushes) == 0 || flushes[0].Index != 0 || len(closes) != 1 || closes[0].Index != 2 {
+		t.Fatalf("flush events %+v and close events %+v", flushes, closes)
+	}
+}
+
+func TestMultiReaderHooks(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("6869"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytesAt := map[int]int{}
+	if err := mr.SetHooks(Hooks{OnRead: func(e HookEvent) { bytesAt[e.Index] += e.Bytes }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(NewHexReader()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(mr); err != nil {
+		t.Fatal(err)
+	}
+	if bytesAt[0] != 4 || bytesAt[1] != 2 {
+		t.Fatalf("read events by layer %v, want 4 bytes from the base and 2 from the hex layer", bytesAt)
+	}
+}
@@ -0,0 +1,96 @@
+package iochain
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"io"
+)
+
+// IdempotentReader reads messages written by IdempotentWriter and skips messages whose
+// key has been seen recently. Seen keys are kept in an LRU set of bounded capacity, so a
+// duplicate arriving after capacity newer keys is delivered again.
+type IdempotentReader struct {
+	src      *bufio.Reader
+	capacity int
+	seen     map[string]*list.Element
+	order    *list.List // most recently seen key at the front
+}
+
+// NewIdempotentReader creates an IdempotentReader reading from r that remembers up to
+// capacity keys.
+func NewIdempotentReader(r io.Reader, capacity int) *IdempotentReader {
+	return &IdempotentReader{
+		src:      bufio.NewReader(r),
+		capacity: capacity,
+		seen:     make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// ReadMessage returns the next message with a key not seen before.
+// It returns io.EOF when the stream ends at a message boundary.
+func (m *IdempotentReader) ReadMessage() (key string, p []byte, err error) {
+	for {
+		k, err := m.readField()
+		if err != nil {
+			return "", nil, err
+		}
+		payload, err := m.readField()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		key := string(k)
+		if m.remember(key) {
+			return key, payload, nil
+		}
+	}
+}
+
+// Reset changes the underlying reader. Seen keys are kept, so duplicates are detected
+// across reconnects.
+func (m *IdempotentReader) Reset(r io.Reader) error {
+	m.src.Reset(r)
+	return nil
+}
+
+// remember records key and reports whether it was new.
+func (m *IdempotentReader) remember(key string) bool {
+	if e, ok := m.seen[key]; ok {
+		m.order.MoveToFront(e)
+		return false
+	}
+	if m.capacity <= 0 {
+		return true
+	}
+	m.seen[key] = m.order.PushFront(key)
+	if m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.seen, oldest.Value.(string))
+	}
+	return true
+}
+
+// readField reads a length-prefixed field. It returns io.EOF only if no byte was read.
+func (m *IdempotentReader) readField() ([]byte, error) {
+	size, err := binary.ReadUvarint(m.src)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxMessageField {
+		return nil, ErrMessageTooLarge
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(m.src, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,52 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIdempotentReaderDropsDuplicates(t *testing.T) {
+	var stream bytes.Buffer
+	w := NewIdempotentWriter(&stream)
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		if err := w.WriteMessage(key, []byte("payload "+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With room for two keys, the repeated "a" is kept fresh and dropped each time,
+	// while "b" is evicted by "c" and delivered again.
+	r := NewIdempotentReader(&stream, 2)
+	var got []string
+	for {
+		key, p, err := r.ReadMessage()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != "payload "+key {
+			t.Fatalf("message %q has payload %q", key, p)
+		}
+		got = append(got, key)
+	}
+	if keys := strings.Join(got, " "); keys != "a b c b" {
+		t.Fatalf("delivered %q, want %q", keys, "a b c b")
+	}
+}
+
+func TestIdempotentReaderTruncatedMessage(t *testing.T) {
+	var stream bytes.Buffer
+	if err := NewIdempotentWriter(&stream).WriteMessage("key", []byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+	for _, cut := range []int{1, 4, stream.Len() - 1} {
+		r := NewIdempotentReader(bytes.NewReader(stream.Bytes()[:cut]), 8)
+		if _, _, err := r.ReadMessage(); err != io.ErrUnexpectedEOF {
+			t.Errorf("stream cut at %d: error = %v, want io.ErrUnexpectedEOF", cut, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package iochain
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// maxMessageField bounds the size of keys and payloads accepted by IdempotentReader.
+const maxMessageField = 64 << 20
+
+// ErrMessageTooLarge is returned for message keys or payloads above 64 MiB.
+var ErrMessageTooLarge = errors.New("message field too large")
+
+// IdempotentWriter frames messages with a caller-supplied idempotency key so that an
+// IdempotentReader can drop replayed duplicates. Each message is written as
+// uvarint(len(key)) key uvarint(len(payload)) payload, in a single Write.
+type IdempotentWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// NewIdempotentWriter creates an IdempotentWriter writing to w.
+func NewIdempotentWriter(w io.Writer) *IdempotentWriter {
+	return &IdempotentWriter{w: w}
+}
+
+// WriteMessage writes p framed with key.
+func (m *IdempotentWriter) WriteMessage(key string, p []byte) error {
+	if len(key) > maxMessageField || len(p) > maxMessageField {
+		return ErrMessageTooLarge
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buf = binary.AppendUvarint(m.buf[:0], uint64(len(key)))
+	m.buf = append(m.buf, key...)
+	m.buf = binary.AppendUvarint(m.buf, uint64(len(p)))
+	m.buf = append(m.buf, p...)
+
+	n, err := m.w.Write(m.buf)
+	if err == nil && n < len(m.buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// Reset changes the underlying writer.
+func (m *IdempotentWriter) Reset(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.w = w
+	return nil
+}
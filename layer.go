@@ -0,0 +1,111 @@
+package iochain
+
+import "io"
+
+// Layer is a symmetric transform, such as compression or encryption, that can wrap both
+// the writing and the reading side of a stream.
+type Layer interface {
+	// WrapWriter returns a writer that encodes into w.
+	WrapWriter(w io.Writer) (io.Writer, error)
+	// WrapReader returns a reader that decodes from r.
+	WrapReader(r io.Reader) (io.Reader, error)
+}
+
+// LayerFuncs implements Layer with a pair of functions.
+type LayerFuncs struct {
+	Writer func(w io.Writer) (io.Writer, error)
+	Reader func(r io.Reader) (io.Reader, error)
+}
+
+func (l LayerFuncs) WrapWriter(w io.Writer) (io.Writer, error) {
+	return l.Writer(w)
+}
+
+func (l LayerFuncs) WrapReader(r io.Reader) (io.Reader, error) {
+	return l.Reader(r)
+}
+
+// LayerWriter returns a ResettableWriter whose Reset wraps the new target with l, so a
+// Layer can be pushed onto a StackWriter. Flush and Close delegate to the wrapped writer.
+func LayerWriter(l Layer) ResettableWriter {
+	return &layerWriter{layer: l}
+}
+
+// LayerReader returns a ResettableReader whose Reset wraps the new source with l, so a
+// Layer can be pushed onto a MultiReader. Close delegates to the wrapped reader.
+func LayerReader(l Layer) ResettableReader {
+	return &layerReader{layer: l}
+}
+
+type layerWriter struct {
+	layer Layer
+	w     io.Writer
+}
+
+func (lw *layerWriter) Write(p []byte) (int, error) {
+	if lw.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return lw.w.Write(p)
+}
+
+func (lw *layerWriter) Reset(w io.Writer) error {
+	wrapped, err := lw.layer.WrapWriter(w)
+	if err != nil {
+		return err
+	}
+	lw.w = wrapped
+	return nil
+}
+
+func (lw *layerWriter) Flush() error {
+	if flusher, ok := lw.w.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (lw *layerWriter) Close() error {
+	if closer, ok := lw.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the writer built by the layer.
+func (lw *layerWriter) Unwrap() io.Writer {
+	return lw.w
+}
+
+type layerReader struct {
+	layer Layer
+	r     io.Reader
+}
+
+func (lr *layerReader) Read(p []byte) (int, error) {
+	if lr.r == nil {
+		return 0, io.EOF
+	}
+	return lr.r.Read(p)
+}
+
+func (lr *layerReader) Reset(r io.Reader) error {
+	wrapped, err := lr.layer.WrapReader(r)
+	if err != nil {
+		return err
+	}
+	lr.r = wrapped
+	return nil
+}
+
+// Unwrap returns the reader built by the layer.
+func (lr *layerReader) Unwrap() io.Reader {
+	return lr.r
+}
+
+func (lr *layerReader) Close() error {
+	if closer, ok := lr.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package iochain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// LayerError identifies the layer of a StackWriter or MultiReader that returned Err.
+// Index counts from the base at 0, as it was when the layer was added.
+type LayerError struct {
+	Index int
+	Layer string // type of the layer, e.g. "*gzip.Writer"
+	Err   error
+}
+
+func (e *LayerError) Error() string {
+	return fmt.Sprintf("iochain: layer %d (%s): %v", e.Index, e.Layer, e.Err)
+}
+
+func (e *LayerError) Unwrap() error {
+	return e.Err
+}
+
+// layerErr wraps err in a *LayerError for layer l at index, unless it is nil, io.EOF
+// or already attributed to a layer further down.
+func layerErr(index int, l any, err error) error {
+	var le *LayerError
+	if err == nil || err == io.EOF || errors.As(err, &le) {
+		return err
+	}
+	return &LayerError{Index: index, Layer: fmt.Sprintf("%T", l), Err: err}
+}
+
+// linkWriter is what a layer of a StackWriter writes into: it forwards to the layer
+// below, attributes its errors, counts its traffic when stats are enabled and reports
+// it to the hooks. WriteString, ReadFrom and Flush use the layer's own methods when it
+// has them, so the layer above can still find them.
+type linkWriter struct {
+	w     io.Writer
+	index int
+	c     *layerCounter // nil unless stats are enabled
+	hooks *Hooks        // nil unless SetHooks was called
+}
+
+func (l *linkWriter) Write(p []byte) (int, error) {
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	n, err := l.w.Write(p)
+	if l.c != nil {
+		l.c.add(n, start)
+	}
+	l.hooks.write(l.index, l.w, n, start, err)
+	return n, layerErr(l.index, l.w, err)
+}
+
+func (l *linkWriter) WriteString(s string) (int, error) {
+	sw, ok := l.w.(io.StringWriter)
+	if !ok {
+		return l.Write([]byte(s))
+	}
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	n, err := sw.WriteString(s)
+	if l.c != nil {
+		l.c.add(n, start)
+	}
+	l.hooks.write(l.index, l.w, n, start, err)
+	return n, layerErr(l.index, l.w, err)
+}
+
+func (l *linkWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := l.w.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{l}, r)
+	}
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	n, err := rf.ReadFrom(r)
+	if l.c != nil {
+		l.c.add(int(n), start)
+	}
+	l.hooks.write(l.index, l.w, int(n), start, err)
+	return n, layerErr(l.index, l.w, err)
+}
+
+// Flush flushes the layer below if it is a Flusher.
+func (l *linkWriter) Flush() error {
+	if flusher, ok := l.w.(Flusher); ok {
+		return layerErr(l.index, l.w, flusher.Flush())
+	}
+	return nil
+}
+
+// link returns the writer the layer above index i should be Reset onto. The lock must
+// be held.
+func (m *StackWriter) link(i int) io.Writer {
+	l := &linkWriter{w: m.writers[i], index: i, hooks: m.hooks}
+	if m.stats != nil {
+		l.c = m.stats[i]
+	}
+	return l
+}
+
+// linkReader is what a layer of a MultiReader reads from: it forwards to the layer
+// below, attributes its errors, counts its traffic when stats are enabled and reports
+// it to the hooks. WriteTo uses the layer's own method when it has one.
+type linkReader struct {
+	r     io.Reader
+	index int
+	c     *layerCounter // nil unless stats are enabled
+	hooks *Hooks        // nil unless SetHooks was called
+}
+
+func (l *linkReader) Read(p []byte) (int, error) {
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	n, err := l.r.Read(p)
+	if l.c != nil {
+		l.c.add(n, start)
+	}
+	l.hooks.read(l.index, l.r, n, start, err)
+	return n, layerErr(l.index, l.r, err)
+}
+
+func (l *linkReader) WriteTo(w io.Writer) (int64, error) {
+	wt, ok := l.r.(io.WriterTo)
+	if !ok {
+		return io.Copy(w, struct{ io.Reader }{l})
+	}
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	n, err := wt.WriteTo(w)
+	if l.c != nil {
+		l.c.add(int(n), start)
+	}
+	l.hooks.read(l.index, l.r, int(n), start, err)
+	return n, layerErr(l.index, l.r, err)
+}
+
+// linkByteReader is the linkReader of a layer that is an io.ByteReader. Decoders such
+// as flate read byte by byte from an io.ByteReader instead of buffering ahead, so it
+// is only offered when the layer below can serve it.
+type linkByteReader struct {
+	*linkReader
+}
+
+func (l linkByteReader) ReadByte() (byte, error) {
+	var start time.Time
+	if l.c != nil || l.hooks != nil {
+		start = time.Now()
+	}
+	b, err := l.r.(io.ByteReader).ReadByte()
+	n := 0
+	if err == nil {
+		n = 1
+	}
+	if l.c != nil {
+		l.c.add(n, start)
+	}
+	l.hooks.read(l.index, l.r, n, start, err)
+	return b, layerErr(l.index, l.r, err)
+}
+
+// link returns the reader the layer above index i should be Reset onto. The lock must
+// be held.
+func (m *MultiReader) link(i int) io.Reader {
+	l := &linkReader{r: m.readers[i], index: i, hooks: m.hooks}
+	if m.stats != nil {
+		l.c = m.stats[i]
+	}
+	if _, ok := l.r.(io.ByteReader); ok {
+		return linkByteReader{l}
+	}
+	return l
+}
@@ -0,0 +1,138 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// bufWriterLayer is a bufio.Writer layer, which hands ReadFrom to the writer below.
+type bufWriterLayer struct{ *bufio.Writer }
+
+func (b bufWriterLayer) Reset(w io.Writer) error {
+	b.Writer.Reset(w)
+	return nil
+}
+
+// bufReaderLayer is a bufio.Reader layer, which hands WriteTo to the reader below.
+type bufReaderLayer struct{ *bufio.Reader }
+
+func (b bufReaderLayer) Reset(r io.Reader) error {
+	b.Reader.Reset(r)
+	return nil
+}
+
+// readFromBase records whether its ReadFrom was used.
+type readFromBase struct {
+	bytes.Buffer
+	readFrom bool
+	err      error
+}
+
+func (b *readFromBase) ReadFrom(r io.Reader) (int64, error) {
+	b.readFrom = true
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.Buffer.ReadFrom(r)
+}
+
+// writeToBase records whether its WriteTo was used.
+type writeToBase struct {
+	*strings.Reader
+	writeTo bool
+}
+
+func (b *writeToBase) WriteTo(w io.Writer) (int64, error) {
+	b.writeTo = true
+	return b.Reader.WriteTo(w)
+}
+
+func TestLinkWriterForwardsReadFrom(t *testing.T) {
+	base := &readFromBase{}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.ReadFrom(strings.NewReader("through ReadFrom")); err != nil {
+		t.Fatal(err)
+	}
+	if !base.readFrom || base.String() != "through ReadFrom" {
+		t.Fatalf("base got %q, ReadFrom used %v", base.String(), base.readFrom)
+	}
+
+	base.err = errors.New("base failed")
+	_, err = sw.ReadFrom(strings.NewReader("more"))
+	var le *LayerError
+	if !errors.As(err, &le) || le.Index != 0 {
+		t.Fatalf("ReadFrom error = %v, want a LayerError for layer 0", err)
+	}
+}
+
+func TestLinkWriterForwardsStringWriterAndFlush(t *testing.T) {
+	sw, err := NewStackWriter(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	link := sw.link(1)
+	if _, ok := link.(io.StringWriter); !ok {
+		t.Error("link does not implement io.StringWriter")
+	}
+	if _, ok := link.(Flusher); !ok {
+		t.Error("link does not implement Flusher")
+	}
+}
+
+func TestLinkReaderForwardsWriteTo(t *testing.T) {
+	base := &writeToBase{Reader: strings.NewReader("through WriteTo")}
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(bufReaderLayer{bufio.NewReader(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := mr.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !base.writeTo || out.String() != "through WriteTo" {
+		t.Fatalf("read %q, WriteTo used %v", out.String(), base.writeTo)
+	}
+}
+
+func TestLinkReaderForwardsByteReader(t *testing.T) {
+	var stream bytes.Buffer
+	fw, _ := flate.NewWriter(&stream, flate.BestSpeed)
+	fw.Write([]byte("compressed"))
+	fw.Close()
+	stream.WriteString("trailer")
+
+	flateLayer := LayerFuncs{Reader: func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }}
+	base := bytes.NewReader(stream.Bytes())
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(LayerReader(flateLayer)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "compressed" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+	// flate reads byte by byte from an io.ByteReader, leaving the trailer unread.
+	if base.Len() != len("trailer") {
+		t.Fatalf("%d bytes left in the base, want %d", base.Len(), len("trailer"))
+	}
+}
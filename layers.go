@@ -0,0 +1,86 @@
+package iochain
+
+import "io"
+
+// Layered is implemented by StackWriter and MultiReader, for use with FindLayer.
+type Layered interface {
+	layerList() []any
+}
+
+// Len is an alias for Depth.
+func (m *StackWriter) Len() int {
+	return m.Depth()
+}
+
+// Layers returns a copy of the writers in the stack, from base to top.
+func (m *StackWriter) Layers() []io.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]io.Writer(nil), m.writers...)
+}
+
+func (m *StackWriter) layerList() []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	layers := make([]any, len(m.writers))
+	for i, w := range m.writers {
+		layers[i] = w
+	}
+	return layers
+}
+
+// Len is an alias for Depth.
+func (m *MultiReader) Len() int {
+	return m.Depth()
+}
+
+// Layers returns a copy of the readers in the chain, from base to top.
+func (m *MultiReader) Layers() []io.Reader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]io.Reader(nil), m.readers...)
+}
+
+func (m *MultiReader) layerList() []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	layers := make([]any, len(m.readers))
+	for i, r := range m.readers {
+		layers[i] = r
+	}
+	return layers
+}
+
+// FindLayer returns the top-most layer of c that has type T. Adapters such as those
+// returned by AdaptWriter, WrapWriter, LayerWriter and LayerReader are looked through
+// via their Unwrap method, so FindLayer[*gzip.Writer] finds an adapted gzip writer.
+func FindLayer[T any](c Layered) (T, bool) {
+	layers := c.layerList()
+	for i := len(layers) - 1; i >= 0; i-- {
+		for l := layers[i]; l != nil; {
+			if t, ok := l.(T); ok {
+				return t, true
+			}
+			l = unwrapLayer(l)
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// unwrapLayer returns the layer wrapped by l, or nil.
+func unwrapLayer(l any) any {
+	switch u := l.(type) {
+	case interface{ Unwrap() io.Writer }:
+		if w := u.Unwrap(); w != nil {
+			return w
+		}
+	case interface{ Unwrap() io.Reader }:
+		if r := u.Unwrap(); r != nil {
+			return r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package iochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestStackWriterIntrospection(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw := NewChecksumWriter(sha256.New())
+	for _, w := range []ResettableWriter{cw, AdaptWriter(gzip.NewWriter(nil)), NewTeeWriter(&bytes.Buffer{})} {
+		if err := sw.AddWriter(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	layers := sw.Layers()
+	if sw.Len() != 4 || len(layers) != 4 || layers[0] != &out || layers[1] != cw {
+		t.Fatalf("Len = %d, Layers = %v", sw.Len(), layers)
+	}
+	if got, ok := FindLayer[*ChecksumWriter](sw); !ok || got != cw {
+		t.Fatalf("FindLayer[*ChecksumWriter] = %v, %v", got, ok)
+	}
+	if _, ok := FindLayer[*gzip.Writer](sw); !ok {
+		t.Fatal("FindLayer did not look through the adapter to the gzip writer")
+	}
+	if _, ok := FindLayer[*HexWriter](sw); ok {
+		t.Fatal("FindLayer found a layer that is not in the stack")
+	}
+}
+
+func TestMultiReaderIntrospection(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Close()
+
+	mr, err := NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(LayerReader(gzipLayer)); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Len() != 2 || len(mr.Layers()) != 2 {
+		t.Fatalf("Len = %d, Layers = %v", mr.Len(), mr.Layers())
+	}
+	if _, ok := FindLayer[*gzip.Reader](mr); !ok {
+		t.Fatal("FindLayer did not find the gzip reader built by the layer")
+	}
+}
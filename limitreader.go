@@ -0,0 +1,69 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+)
+
+var _ ResettableReader = (*LimitReader)(nil)
+
+// ErrReadLimitExceeded is returned by a strict LimitReader when its source holds more
+// than the allowed bytes.
+var ErrReadLimitExceeded = errors.New("read limit exceeded")
+
+// LimitReader returns at most max bytes from its source. Once they have been read it
+// returns io.EOF, or, if Strict is set and the source has more data, ErrReadLimitExceeded.
+type LimitReader struct {
+	// Strict makes reads past the limit fail with ErrReadLimitExceeded instead of io.EOF
+	// when the source is not exhausted. Checking consumes one byte of the source.
+	Strict bool
+
+	src  io.Reader
+	max  int64
+	read int64
+}
+
+// NewLimitReader creates a LimitReader allowing max bytes. The source is set with Reset,
+// typically by MultiReader.AddReader.
+func NewLimitReader(max int64) *LimitReader {
+	return &LimitReader{max: max}
+}
+
+// Read reads from the source, truncated to the remaining allowance.
+func (l *LimitReader) Read(p []byte) (int, error) {
+	if l.src == nil {
+		return 0, io.EOF
+	}
+	remaining := l.Remaining()
+	if remaining == 0 {
+		if l.Strict && len(p) > 0 {
+			var probe [1]byte
+			n, err := io.ReadFull(l.src, probe[:])
+			if n > 0 {
+				return 0, ErrReadLimitExceeded
+			}
+			if err != io.EOF {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.src.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// Remaining returns the number of bytes that can still be read.
+func (l *LimitReader) Remaining() int64 {
+	return max(l.max-l.read, 0)
+}
+
+// Reset changes the source. The byte count is kept, so the limit applies to everything
+// read through the LimitReader.
+func (l *LimitReader) Reset(src io.Reader) error {
+	l.src = src
+	return nil
+}
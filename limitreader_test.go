@@ -0,0 +1,35 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimitReaderStrict(t *testing.T) {
+	errSource := errors.New("source failed")
+	for _, tc := range []struct {
+		name string
+		src  io.Reader
+		want error
+	}{
+		{"exhausted", strings.NewReader("abc"), io.EOF},
+		{"more data", strings.NewReader("abcd"), ErrReadLimitExceeded},
+		{"source error", io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errSource)), errSource},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimitReader(3)
+			l.Strict = true
+			l.Reset(tc.src)
+			buf := make([]byte, 3)
+			if _, err := io.ReadFull(l, buf); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := l.Read(buf); err != tc.want {
+				t.Fatalf("Read past the limit = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
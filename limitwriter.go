@@ -0,0 +1,64 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+)
+
+var _ ResettableWriter = (*LimitWriter)(nil)
+
+// ErrWriteLimitExceeded is returned by LimitWriter once its byte limit is reached.
+var ErrWriteLimitExceeded = errors.New("write limit exceeded")
+
+// LimitWriter forwards at most max bytes to its downstream writer. A write that would
+// cross the limit forwards only the bytes that fit and returns ErrWriteLimitExceeded, as
+// do all writes after it.
+type LimitWriter struct {
+	w        io.Writer
+	max      int64
+	written  int64
+	exceeded bool
+}
+
+// NewLimitWriter creates a LimitWriter allowing max bytes. The downstream writer is set
+// with Reset, typically by StackWriter.AddWriter.
+func NewLimitWriter(max int64) *LimitWriter {
+	return &LimitWriter{max: max}
+}
+
+// Write forwards p, truncated to the remaining allowance.
+func (l *LimitWriter) Write(p []byte) (int, error) {
+	if l.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	if l.exceeded {
+		return 0, ErrWriteLimitExceeded
+	}
+
+	chunk := p
+	if remaining := l.Remaining(); int64(len(chunk)) > remaining {
+		chunk = chunk[:remaining]
+		l.exceeded = true
+		if remaining == 0 {
+			return 0, ErrWriteLimitExceeded
+		}
+	}
+	n, err := l.w.Write(chunk)
+	l.written += int64(n)
+	if err == nil && l.exceeded {
+		err = ErrWriteLimitExceeded
+	}
+	return n, err
+}
+
+// Remaining returns the number of bytes that can still be written.
+func (l *LimitWriter) Remaining() int64 {
+	return max(l.max-l.written, 0)
+}
+
+// Reset changes the downstream writer. The byte count is kept, so the limit applies to
+// everything written through the LimitWriter.
+func (l *LimitWriter) Reset(w io.Writer) error {
+	l.w = w
+	return nil
+}
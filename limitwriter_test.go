@@ -0,0 +1,56 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newLimitedStack(t *testing.T, limit int64) (*StackWriter, *LimitWriter, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw := NewLimitWriter(limit)
+	if err := sw.AddWriter(lw); err != nil {
+		t.Fatal(err)
+	}
+	return sw, lw, &out
+}
+
+func TestLimitWriterExactBoundary(t *testing.T) {
+	sw, lw, out := newLimitedStack(t, 8)
+	if n, err := sw.Write([]byte("1234")); n != 4 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if n, err := sw.Write([]byte("5678")); n != 4 || err != nil {
+		t.Fatalf("Write up to the limit = %d, %v", n, err)
+	}
+	if lw.Remaining() != 0 {
+		t.Fatalf("Remaining = %d, want 0", lw.Remaining())
+	}
+	if n, err := sw.Write([]byte("9")); n != 0 || !errors.Is(err, ErrWriteLimitExceeded) {
+		t.Fatalf("Write past the limit = %d, %v", n, err)
+	}
+	if out.String() != "12345678" {
+		t.Fatalf("downstream got %q", out.String())
+	}
+}
+
+func TestLimitWriterStraddlingWrite(t *testing.T) {
+	sw, lw, out := newLimitedStack(t, 5)
+	n, err := sw.Write([]byte("abcdefgh"))
+	if n != 5 || !errors.Is(err, ErrWriteLimitExceeded) {
+		t.Fatalf("Write = %d, %v, want 5, %v", n, err, ErrWriteLimitExceeded)
+	}
+	for range 2 {
+		if n, err := sw.Write([]byte("x")); n != 0 || !errors.Is(err, ErrWriteLimitExceeded) {
+			t.Fatalf("Write after the limit = %d, %v", n, err)
+		}
+	}
+	if lw.Remaining() != 0 || out.String() != "abcde" {
+		t.Fatalf("Remaining = %d, downstream got %q", lw.Remaining(), out.String())
+	}
+}
@@ -0,0 +1,104 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+)
+
+var _ ResettableReader = (*LineTransformReader)(nil)
+
+// LineTransformReader calls a LineTransformFunc for every record read from its source
+// and returns the results, each followed by the delimiter. A final record without a
+// delimiter is transformed too and returned without one.
+type LineTransformReader struct {
+	// Delimiter ends each record. It defaults to '\n'.
+	Delimiter byte
+	// MaxRecordSize limits the length of a buffered record; 0 means no limit.
+	MaxRecordSize int
+
+	r   io.Reader
+	fn  LineTransformFunc
+	in  []byte // bytes read from r not yet split into records
+	out []byte // transformed bytes not yet returned
+	err error  // error from r, returned once in and out are drained
+}
+
+// NewLineTransformReader creates a LineTransformReader calling fn. The source is set
+// with Reset, typically by MultiReader.AddReader.
+func NewLineTransformReader(fn LineTransformFunc) *LineTransformReader {
+	return &LineTransformReader{Delimiter: '\n', fn: fn}
+}
+
+// Read reads transformed records into p.
+func (t *LineTransformReader) Read(p []byte) (int, error) {
+	if t.r == nil {
+		return 0, io.EOF
+	}
+
+	for len(t.out) == 0 {
+		if i := bytes.IndexByte(t.in, t.Delimiter); i >= 0 {
+			if err := t.emit(t.in[:i], true); err != nil {
+				return 0, err
+			}
+			t.in = t.in[i+1:]
+			continue
+		}
+		if t.MaxRecordSize > 0 && len(t.in) > t.MaxRecordSize {
+			return 0, ErrRecordTooLarge
+		}
+		if t.err != nil {
+			if len(t.in) == 0 || t.err != io.EOF {
+				return 0, t.err
+			}
+			rec := t.in
+			t.in = nil
+			if err := t.emit(rec, false); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		t.fill()
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// Reset changes the source and discards buffered data.
+func (t *LineTransformReader) Reset(src io.Reader) error {
+	t.r = src
+	t.in, t.out, t.err = nil, nil, nil
+	return nil
+}
+
+// fill reads more data from the source into t.in.
+func (t *LineTransformReader) fill() {
+	if len(t.in) == cap(t.in) {
+		// Move the pending bytes to a larger buffer, reclaiming the consumed prefix.
+		in := make([]byte, len(t.in), 2*len(t.in)+4096)
+		copy(in, t.in)
+		t.in = in
+	}
+	n, err := t.r.Read(t.in[len(t.in):cap(t.in)])
+	t.in = t.in[:len(t.in)+n]
+	if err != nil {
+		t.err = err
+	}
+}
+
+// emit transforms rec and queues the result for Read.
+func (t *LineTransformReader) emit(rec []byte, delimited bool) error {
+	if t.MaxRecordSize > 0 && len(rec) > t.MaxRecordSize {
+		return ErrRecordTooLarge
+	}
+	out, err := t.fn(rec)
+	if err != nil || out == nil {
+		return err
+	}
+	t.out = append(t.out[:0], out...)
+	if delimited {
+		t.out = append(t.out, t.Delimiter)
+	}
+	return nil
+}
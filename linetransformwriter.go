@@ -0,0 +1,103 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var _ ResettableWriter = (*LineTransformWriter)(nil)
+
+// ErrRecordTooLarge is returned by the line transform layers for records longer than MaxRecordSize.
+var ErrRecordTooLarge = errors.New("record too large")
+
+// LineTransformFunc transforms one record, given without its delimiter. Returning a
+// nil slice drops the record; an empty non-nil slice keeps it as an empty record.
+type LineTransformFunc func(record []byte) ([]byte, error)
+
+// LineTransformWriter calls a LineTransformFunc for every complete record written
+// through it and writes the result, followed by the delimiter, downstream. Partial
+// records are buffered across writes; the final one is transformed on Close.
+type LineTransformWriter struct {
+	// Delimiter ends each record. It defaults to '\n'.
+	Delimiter byte
+	// MaxRecordSize limits the length of a buffered record; 0 means no limit.
+	MaxRecordSize int
+
+	w   io.Writer
+	fn  LineTransformFunc
+	buf []byte
+	out []byte
+}
+
+// NewLineTransformWriter creates a LineTransformWriter calling fn. The downstream
+// writer is set with Reset, typically by StackWriter.AddWriter.
+func NewLineTransformWriter(fn LineTransformFunc) *LineTransformWriter {
+	return &LineTransformWriter{Delimiter: '\n', fn: fn}
+}
+
+// Write buffers p and transforms every record it completes. The returned count
+// includes bytes of p that were buffered or written as part of a complete record.
+func (t *LineTransformWriter) Write(p []byte) (int, error) {
+	if t.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+
+	written := 0
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, t.Delimiter)
+		if i < 0 {
+			if t.MaxRecordSize > 0 && len(t.buf)+len(p) > t.MaxRecordSize {
+				return written, ErrRecordTooLarge
+			}
+			t.buf = append(t.buf, p...)
+			return written + len(p), nil
+		}
+		if t.MaxRecordSize > 0 && len(t.buf)+i > t.MaxRecordSize {
+			return written, ErrRecordTooLarge
+		}
+
+		t.buf = append(t.buf, p[:i]...)
+		if err := t.emit(true); err != nil {
+			return written, err
+		}
+		written += i + 1
+		p = p[i+1:]
+	}
+	return written, nil
+}
+
+// Reset changes the downstream writer and discards any buffered partial record.
+func (t *LineTransformWriter) Reset(w io.Writer) error {
+	t.w = w
+	t.buf = t.buf[:0]
+	return nil
+}
+
+// Close transforms and writes the buffered partial record, if any, without a
+// delimiter. The downstream writer is not closed.
+func (t *LineTransformWriter) Close() error {
+	if len(t.buf) == 0 || t.w == nil {
+		return nil
+	}
+	return t.emit(false)
+}
+
+// emit transforms the buffered record and writes it downstream.
+func (t *LineTransformWriter) emit(delimited bool) error {
+	rec, err := t.fn(t.buf)
+	t.buf = t.buf[:0]
+	if err != nil || rec == nil {
+		return err
+	}
+
+	t.out = append(t.out[:0], rec...)
+	if delimited {
+		t.out = append(t.out, t.Delimiter)
+	}
+	n, err := t.w.Write(t.out)
+	if err == nil && n < len(t.out) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// redactUpper drops records equal to "secret" and upper-cases the others.
+func redactUpper(rec []byte) ([]byte, error) {
+	if string(rec) == "secret" {
+		return nil, nil
+	}
+	return bytes.ToUpper(rec), nil
+}
+
+func TestLineTransformWriter(t *testing.T) {
+	var out bytes.Buffer
+	tw := NewLineTransformWriter(redactUpper)
+	tw.Reset(&out)
+	for _, p := range []string{"one\nsec", "ret\n\ntw", "o"} {
+		if n, err := tw.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ONE\n\nTWO"; out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+
+	tw = NewLineTransformWriter(redactUpper)
+	tw.Delimiter = ';'
+	tw.MaxRecordSize = 4
+	tw.Reset(&out)
+	if _, err := tw.Write([]byte("ok;toolong")); err != ErrRecordTooLarge {
+		t.Fatalf("Write of an oversized record = %v, want %v", err, ErrRecordTooLarge)
+	}
+}
+
+func TestLineTransformReader(t *testing.T) {
+	mr, err := NewReader(iotest.OneByteReader(strings.NewReader("one\nsecret\n\ntwo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(NewLineTransformReader(redactUpper)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "ONE\n\nTWO" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+
+	tr := NewLineTransformReader(redactUpper)
+	tr.MaxRecordSize = 4
+	tr.Reset(strings.NewReader("ok\nway too long\n"))
+	if _, err := io.ReadAll(tr); err != ErrRecordTooLarge {
+		t.Fatalf("ReadAll with an oversized record = %v, want %v", err, ErrRecordTooLarge)
+	}
+}
@@ -0,0 +1,47 @@
+package iochain
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// countingDiscard is a base writer that discards data and counts it.
+type countingDiscard struct {
+	n atomic.Int64
+}
+
+func (c *countingDiscard) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// NewMeasuringStackWriter creates a StackWriter that runs data through layers, added from
+// the base outwards, and discards the result. After Close, MeasuredSize reports the size
+// the transformed output would have had. Flushes are measured too, since they may emit
+// extra bytes such as compressor sync markers. If a layer cannot be added, the layers
+// already added are closed.
+func NewMeasuringStackWriter(layers ...ResettableWriter) (*StackWriter, error) {
+	sink := &countingDiscard{}
+	m, err := NewStackWriter(sink)
+	if err != nil {
+		return nil, err
+	}
+	m.measured = sink
+	for _, w := range layers {
+		if err := m.AddWriter(w); err != nil {
+			return nil, errors.Join(err, m.closeLayers())
+		}
+	}
+	return m, nil
+}
+
+// MeasuredSize returns the number of bytes that reached the discarding base of a
+// StackWriter created by NewMeasuringStackWriter, even if the base was later replaced
+// with ResetBase, or -1 for any other StackWriter. It is safe to call concurrently with
+// writes.
+func (m *StackWriter) MeasuredSize() int64 {
+	if m.measured == nil {
+		return -1
+	}
+	return m.measured.n.Load()
+}
@@ -0,0 +1,75 @@
+package iochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMeasuredSizeAcrossResetBase(t *testing.T) {
+	data := strings.Repeat("measure me ", 500)
+	var want bytes.Buffer
+	zw := gzip.NewWriter(&want)
+	zw.Write([]byte(data))
+	zw.Flush() // FlushAndClose flushes before closing, which adds a sync marker
+	zw.Close()
+
+	m, err := NewMeasuringStackWriter(AdaptWriter(gzip.NewWriter(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(m, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if m.MeasuredSize() != int64(want.Len()) {
+		t.Fatalf("MeasuredSize = %d, want %d", m.MeasuredSize(), want.Len())
+	}
+
+	other, err := NewStackWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.MeasuredSize() != -1 {
+		t.Fatalf("MeasuredSize of a plain StackWriter = %d, want -1", other.MeasuredSize())
+	}
+}
+
+func TestMeasuredSizeConcurrentResetBase(t *testing.T) {
+	m, err := NewMeasuringStackWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Write([]byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			m.MeasuredSize()
+		}
+	}()
+	if err := m.ResetBase(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if m.MeasuredSize() != 5 {
+		t.Fatalf("MeasuredSize after ResetBase = %d, want 5", m.MeasuredSize())
+	}
+}
+
+func TestNewMeasuringStackWriterClosesLayersOnFailure(t *testing.T) {
+	var closed int
+	ok := LayerWriter(closeCountingLayer(&closed))
+	_, err := NewMeasuringStackWriter(ok, LayerWriter(failingLayer))
+	if !errors.Is(err, errLayer) || closed != 1 {
+		t.Fatalf("NewMeasuringStackWriter = %v, closed %d layers, want %v and 1", err, closed, errLayer)
+	}
+}
@@ -1,9 +1,12 @@
 package iochain
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 // ResettableReader is an io.Reader that can be reset to read from another reader.
@@ -12,10 +15,37 @@ type ResettableReader interface {
 	Reset(r io.Reader) error
 }
 
+// ErrNotSeekable is returned by MultiReader.Seek when the base reader is not an io.Seeker.
+var ErrNotSeekable = errors.New("base reader is not seekable")
+
+// ErrAt annotates a read error with the stream offset at which it occurred.
+type ErrAt struct {
+	Offset int64 // bytes returned by the chain before the error, including those of the failing Read
+	Err    error
+}
+
+func (e *ErrAt) Error() string {
+	return fmt.Sprintf("at byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *ErrAt) Unwrap() error {
+	return e.Err
+}
+
 // MultiReader manages a stack of readers, each reading from the previous one.
 type MultiReader struct {
-	mu      sync.Mutex
-	readers []io.Reader // from base to top
+	mu       sync.Mutex
+	base     io.Reader
+	readers  []io.Reader      // from base to top
+	read     atomic.Int64     // bytes returned by Read so far
+	peeked   []byte           // bytes buffered by Peek, served before the top reader
+	stats    []*layerCounter  // output counters parallel to readers, nil unless EnableStats was called
+	hooks    *Hooks           // nil unless SetHooks was called
+	progress *progressTracker // nil unless OnProgress was called
+	template *templateLayers  // set when built by a ChainTemplate
+	closed   bool             // set by Close
+
+	readDeadline atomic.Int64 // UnixNano of the watchdog deadline, 0 if none
 }
 
 // NewReader creates a new MultiReader with a base reader.
@@ -24,11 +54,18 @@ func NewReader(base io.Reader) (*MultiReader, error) {
 		return nil, errors.New("base reader cannot be nil")
 	}
 	return &MultiReader{
+		base:    base,
 		readers: []io.Reader{base},
 	}, nil
 }
 
 // AddReader wraps the current top reader with a new ResettableReader.
+//
+// Bytes buffered by Peek are passed to r ahead of the previous top reader.
+//
+// If r.Reset fails, the chain is left exactly as it was: r is not added and the previous
+// top reader keeps serving Reads. Any data r consumed from it during the failed Reset
+// is not restored.
 func (m *MultiReader) AddReader(r ResettableReader) error {
 	if r == nil {
 		return errors.New("reader cannot be nil")
@@ -37,39 +74,384 @@ func (m *MultiReader) AddReader(r ResettableReader) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	prev := m.readers[len(m.readers)-1]
+	if m.closed {
+		return ErrChainClosed
+	}
+	prev := m.link(len(m.readers) - 1)
+	var pb *pushbackReader
+	if len(m.peeked) > 0 {
+		pb = &pushbackReader{buf: m.peeked, r: prev}
+		prev = pb
+		m.peeked = nil
+	}
 	if err := r.Reset(prev); err != nil {
-		return err
+		if pb != nil {
+			m.peeked = pb.buf
+		}
+		return fmt.Errorf("iochain: reset failed while adding reader: %w", err)
 	}
 
 	m.readers = append(m.readers, r)
+	if m.stats != nil {
+		m.stats = append(m.stats, new(layerCounter))
+	}
 	return nil
 }
 
+// Depth returns the number of readers in the chain, counting the base.
+// It returns 0 once the chain has been closed.
+func (m *MultiReader) Depth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.readers)
+}
+
+// Base returns the base reader the chain was created with.
+func (m *MultiReader) Base() io.Reader {
+	return m.base
+}
+
 // Read reads from the top-most reader in the chain.
+// Errors other than io.EOF are wrapped in an *ErrAt carrying the stream offset, around a
+// *LayerError identifying the reader that failed.
 func (m *MultiReader) Read(p []byte) (int, error) {
+	if ctx, cancel := watchdogContext(&m.readDeadline); ctx != nil {
+		defer cancel()
+		n, err := m.ReadContext(ctx, p)
+		return n, deadlineErr(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readTop(p)
+}
+
+// readTop implements Read. The lock must be held.
+func (m *MultiReader) readTop(p []byte) (int, error) {
+	if m.closed {
+		return 0, ErrChainClosed
+	}
+	if len(m.peeked) > 0 {
+		n := copy(p, m.peeked)
+		m.peeked = m.peeked[n:]
+		m.addRead(int64(n))
+		return n, nil
+	}
+	top := m.readers[len(m.readers)-1]
+	start := m.callStart()
+	n, err := top.Read(p)
+	m.countTop(n, start, err)
+	offset := m.addRead(int64(n))
+	if err != nil && err != io.EOF {
+		err = &ErrAt{Offset: offset, Err: layerErr(len(m.readers)-1, top, err)}
+	}
+	return n, err
+}
+
+// ReadContext reads from the top-most reader unless ctx is done first. It returns
+// ctx.Err() without reading if ctx is already done, and stops waiting for the lock or
+// the read once ctx is cancelled. A read that has already started cannot be
+// interrupted: it completes in the background, and the bytes it returns are kept for
+// the next Read as if they had been peeked.
+func (m *MultiReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	var (
+		smu       sync.Mutex // guards abandoned and delivered
+		abandoned bool
+		delivered bool
+	)
+	done := make(chan result, 1)
+	buf := make([]byte, len(p))
+	go func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			done <- result{0, err}
+			return
+		}
+		n, err := m.readTop(buf)
+
+		smu.Lock()
+		defer smu.Unlock()
+		if abandoned {
+			m.peeked = append(buf[:n:n], m.peeked...)
+			m.read.Add(-int64(n))
+			return
+		}
+		delivered = true
+		done <- result{n, err}
+	}()
+
+	select {
+	case r := <-done:
+		return copy(p, buf[:r.n]), r.err
+	case <-ctx.Done():
+		smu.Lock()
+		if delivered {
+			smu.Unlock()
+			r := <-done
+			return copy(p, buf[:r.n]), r.err
+		}
+		abandoned = true
+		smu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// Peek returns the next n bytes without consuming them; they are returned again by
+// subsequent Reads. If fewer than n bytes are available, Peek returns them along with
+// the error that stopped it, io.EOF at the end of the stream. As with bufio.Reader, the
+// returned slice is only valid until the next call on the MultiReader.
+func (m *MultiReader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("peek count cannot be negative")
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.readers) == 0 {
-		return 0, io.EOF
+	if m.closed {
+		return nil, ErrChainClosed
 	}
-	return m.readers[len(m.readers)-1].Read(p)
+	top := m.readers[len(m.readers)-1]
+	for empty := 0; len(m.peeked) < n; {
+		if cap(m.peeked) < n {
+			m.peeked = append(make([]byte, 0, n), m.peeked...)
+		}
+		start := m.callStart()
+		nr, err := top.Read(m.peeked[len(m.peeked):n])
+		m.countTop(nr, start, err)
+		m.peeked = m.peeked[:len(m.peeked)+nr]
+		if nr == 0 && err == nil {
+			if empty++; empty >= 100 {
+				err = io.ErrNoProgress
+			}
+		}
+		if err == io.EOF {
+			return m.peeked, io.EOF
+		}
+		if err != nil {
+			return m.peeked, &ErrAt{Offset: m.read.Load() + int64(len(m.peeked)), Err: layerErr(len(m.readers)-1, top, err)}
+		}
+	}
+	return m.peeked[:n], nil
+}
+
+// UnreadBytes pushes p back in front of the stream: it is returned by the next Reads
+// and passed to readers added afterwards, ahead of any peeked bytes. BytesRead is
+// reduced by len(p), so p should be bytes just read from the chain.
+func (m *MultiReader) UnreadBytes(p []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	m.peeked = append(append(make([]byte, 0, len(p)+len(m.peeked)), p...), m.peeked...)
+	m.read.Add(-int64(len(p)))
+	return nil
+}
+
+// Buffered returns the number of peeked or unread bytes waiting to be returned.
+func (m *MultiReader) Buffered() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.peeked)
+}
+
+// Seek seeks the base reader and rebuilds the chain on top of it by resetting every
+// layer from the base upwards, so reading resumes at offset in the base stream. This
+// suits formats whose layers can restart at known boundaries, such as the start of a
+// compressed member. Offsets are in base stream coordinates; io.SeekCurrent is relative
+// to the base's own position, which may be ahead of the data returned so far because
+// layers read ahead. Peeked bytes are discarded and BytesRead is set to the new offset.
+//
+// Seek returns ErrNotSeekable if the base does not implement io.Seeker. If a layer
+// fails to reset, the error identifies it and the chain should be closed.
+func (m *MultiReader) Seek(offset int64, whence int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrChainClosed
+	}
+	seeker, ok := m.readers[0].(io.Seeker)
+	if !ok {
+		return 0, ErrNotSeekable
+	}
+	pos, err := seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, layerErr(0, m.readers[0], err)
+	}
+
+	m.peeked = nil
+	m.read.Store(pos)
+	for i := 1; i < len(m.readers); i++ {
+		if err := m.readers[i].(ResettableReader).Reset(m.link(i - 1)); err != nil {
+			return pos, fmt.Errorf("iochain: rebuilding chain after seek: %w", layerErr(i, m.readers[i], err))
+		}
+	}
+	return pos, nil
+}
+
+// WriteTo drains the top-most reader into w until io.EOF, using the top reader's
+// io.WriterTo implementation when it has one. Like io.Copy, it returns a nil error at
+// io.EOF. Other errors are wrapped in an *ErrAt carrying the stream offset, as in Read;
+// errors of the top reader, including those of its WriteTo, are also wrapped in a
+// *LayerError. It holds the lock for the whole copy.
+func (m *MultiReader) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrChainClosed
+	}
+	top := m.readers[len(m.readers)-1]
+
+	var total int64
+	for len(m.peeked) > 0 {
+		nw, err := w.Write(m.peeked)
+		m.peeked = m.peeked[nw:]
+		offset := m.addRead(int64(nw))
+		total += int64(nw)
+		if err == nil && nw == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return total, &ErrAt{Offset: offset, Err: err}
+		}
+	}
+
+	if wt, ok := top.(io.WriterTo); ok {
+		start := m.callStart()
+		n, err := wt.WriteTo(w)
+		m.countTop(int(n), start, err)
+		offset := m.addRead(n)
+		if err != nil {
+			err = &ErrAt{Offset: offset, Err: layerErr(len(m.readers)-1, top, err)}
+		}
+		return total + n, err
+	}
+
+	bufp := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(bufp)
+	buf := *bufp
+	for {
+		start := m.callStart()
+		nr, rerr := top.Read(buf)
+		m.countTop(nr, start, rerr)
+		offset := m.addRead(int64(nr))
+		if nr > 0 {
+			nw, werr := w.Write(buf[:nr])
+			total += int64(nw)
+			if werr == nil && nw < nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				return total, &ErrAt{Offset: offset, Err: werr}
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, &ErrAt{Offset: offset, Err: layerErr(len(m.readers)-1, top, rerr)}
+		}
+	}
+}
+
+// topIsWriterTo reports whether the top reader implements io.WriterTo.
+func (m *MultiReader) topIsWriterTo() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return false
+	}
+	_, ok := m.readers[len(m.readers)-1].(io.WriterTo)
+	return ok
+}
+
+// BytesRead returns the total number of bytes returned by Read.
+// It is safe to call concurrently with Read.
+func (m *MultiReader) BytesRead() int64 {
+	return m.read.Load()
 }
 
 // Close calls Close() on each reader from top to base if it implements io.Closer.
+// Each reader in the chain is closed exactly once, here; layers do not close the
+// reader they were Reset onto.
+// All errors are returned, joined in that order. Once closed, the chain rejects
+// further use with ErrChainClosed; closing it again does nothing and returns nil.
 func (m *MultiReader) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var firstErr error
+	if m.closed {
+		return nil
+	}
+	var errs []error
 	for i := len(m.readers) - 1; i >= 0; i-- {
 		if closer, ok := m.readers[i].(io.Closer); ok {
-			if err := closer.Close(); err != nil && firstErr == nil {
-				firstErr = err
+			start := m.callStart()
+			err := closer.Close()
+			m.hooks.close(i, m.readers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.readers[i], err))
 			}
 		}
 	}
 	m.readers = nil
-	return firstErr
+	m.closed = true
+	if m.progress != nil {
+		m.progress.finish(m.read.Load())
+	}
+	m.peeked = nil
+	return errors.Join(errs...)
+}
+
+// CloseWithContext runs Close but returns ctx.Err() if ctx is done before it finishes.
+// The close then completes in the background and its errors are lost.
+func (m *MultiReader) CloseWithContext(ctx context.Context) error {
+	return closeWithContext(ctx, m.Close)
+}
+
+// closeWithContext runs closeFn in the background and waits for it or for ctx.
+func closeWithContext(ctx context.Context, closeFn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- closeFn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pushbackReader returns buf before reading from r.
+type pushbackReader struct {
+	buf []byte
+	r   io.Reader
+}
+
+func (p *pushbackReader) Read(b []byte) (int, error) {
+	if len(p.buf) > 0 {
+		n := copy(b, p.buf)
+		p.buf = p.buf[n:]
+		return n, nil
+	}
+	return p.r.Read(b)
 }
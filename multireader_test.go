@@ -0,0 +1,345 @@
+package iochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestBytesReadTwoLayers(t *testing.T) {
+	data := strings.Repeat("counted ", 1000)
+	mr, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := mr.AddReader(&PassthroughReader{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(mr, head); err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n+10 != int64(len(data)) || mr.BytesRead() != int64(len(data)) {
+		t.Fatalf("copied %d, BytesRead = %d, want %d", n+10, mr.BytesRead(), len(data))
+	}
+}
+
+// failingResetReader is a ResettableReader whose Reset always fails.
+type failingResetReader struct{ err error }
+
+func (f *failingResetReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (f *failingResetReader) Reset(io.Reader) error { return f.err }
+
+func TestAddReaderResetFailureLeavesChain(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(&PassthroughReader{}); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := &failingResetReader{err: errors.New("no reset")}
+	if err := mr.AddReader(fail); !errors.Is(err, fail.err) {
+		t.Fatalf("AddReader = %v, want %v", err, fail.err)
+	}
+	if mr.Depth() != 2 {
+		t.Fatalf("Depth = %d, want 2", mr.Depth())
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "unchanged" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+}
+
+// errWriterToReader is a reader layer whose WriteTo always fails.
+type errWriterToReader struct {
+	PassthroughReader
+	err error
+}
+
+func (e *errWriterToReader) WriteTo(io.Writer) (int64, error) { return 0, e.err }
+
+// failWriter fails every Write.
+type failWriter struct{ err error }
+
+func (f failWriter) Write([]byte) (int, error) { return 0, f.err }
+
+func TestWriteToWrapsErrors(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := &errWriterToReader{err: errors.New("writeto failed")}
+	if err := mr.AddReader(top); err != nil {
+		t.Fatal(err)
+	}
+
+	var at *ErrAt
+	var le *LayerError
+	_, err = mr.WriteTo(io.Discard)
+	if !errors.Is(err, top.err) || !errors.As(err, &at) || !errors.As(err, &le) || le.Index != 1 {
+		t.Fatalf("WriteTo through the top WriterTo = %v", err)
+	}
+
+	if _, err := mr.Peek(3); err != nil {
+		t.Fatal(err)
+	}
+	dst := failWriter{err: errors.New("destination failed")}
+	_, err = mr.WriteTo(dst)
+	if !errors.Is(err, dst.err) || !errors.As(err, &at) {
+		t.Fatalf("WriteTo of peeked bytes = %v", err)
+	}
+}
+
+func TestReadErrorCarriesOffset(t *testing.T) {
+	errCorrupt := errors.New("corrupt block")
+	mr, err := NewReader(io.MultiReader(strings.NewReader("0123456789"), iotest.ErrReader(errCorrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(&PassthroughReader{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(iotest.HalfReader(mr))
+	var at *ErrAt
+	if !errors.As(err, &at) || at.Offset != 10 || !errors.Is(err, errCorrupt) {
+		t.Fatalf("ReadAll = %q, %v, want an ErrAt at offset 10", got, err)
+	}
+	if !strings.Contains(err.Error(), "at byte 10") {
+		t.Fatalf("error %q does not mention the offset", err)
+	}
+}
+
+func TestPeekThenAddReader(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("sniffed"))
+	zw.Close()
+
+	mr, err := NewReader(iotest.OneByteReader(&compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := mr.Peek(2)
+	if err != nil || !bytes.Equal(head, []byte{0x1f, 0x8b}) {
+		t.Fatalf("Peek = %x, %v", head, err)
+	}
+	if err := mr.AddReader(LayerReader(gzipLayer)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "sniffed" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+}
+
+func TestPeekPastEnd(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := mr.Peek(5)
+	if err != io.EOF || string(head) != "ab" {
+		t.Fatalf("Peek = %q, %v, want %q, io.EOF", head, err, "ab")
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "ab" || mr.BytesRead() != 2 {
+		t.Fatalf("ReadAll after Peek = %q, %v, BytesRead %d", got, err, mr.BytesRead())
+	}
+}
+
+// gatedReader returns data on its first Read once release is closed, then io.EOF.
+type gatedReader struct {
+	data    string
+	started chan struct{}
+	release chan struct{}
+	closing chan struct{} // if set, Close blocks until it is closed
+}
+
+func newGatedReader(data string) *gatedReader {
+	return &gatedReader{data: data, started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	select {
+	case g.started <- struct{}{}:
+	default:
+	}
+	<-g.release
+	n := copy(p, g.data)
+	g.data = g.data[n:]
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (g *gatedReader) Close() error {
+	if g.closing != nil {
+		<-g.closing
+	}
+	return nil
+}
+
+func TestReadContextKeepsAbandonedRead(t *testing.T) {
+	base := newGatedReader("late data")
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-base.started
+		cancel()
+	}()
+	if _, err := mr.ReadContext(ctx, make([]byte, 64)); err != context.Canceled {
+		t.Fatalf("ReadContext = %v, want %v", err, context.Canceled)
+	}
+
+	close(base.release)
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "late data" {
+		t.Fatalf("ReadAll after the abandoned read = %q, %v", got, err)
+	}
+	if mr.BytesRead() != int64(len("late data")) {
+		t.Fatalf("BytesRead = %d, want %d", mr.BytesRead(), len("late data"))
+	}
+}
+
+func TestCloseWithContextStopsWaiting(t *testing.T) {
+	base := newGatedReader("")
+	base.closing = make(chan struct{})
+	defer close(base.closing)
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mr.CloseWithContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("CloseWithContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestUnreadBytesAheadOfPeeked(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("headbody"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(mr, head); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Peek(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.UnreadBytes(head); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Buffered() != 6 || mr.BytesRead() != 0 {
+		t.Fatalf("Buffered = %d, BytesRead = %d, want 6 and 0", mr.Buffered(), mr.BytesRead())
+	}
+
+	// Readers added now see the unread bytes first.
+	if err := mr.AddReader(&PassthroughReader{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "headbody" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+
+	mr.Close()
+	if err := mr.UnreadBytes([]byte("x")); err != ErrChainClosed {
+		t.Fatalf("UnreadBytes after Close = %v, want %v", err, ErrChainClosed)
+	}
+}
+
+func TestSeekRebuildsChain(t *testing.T) {
+	var stream bytes.Buffer
+	var second int64
+	for _, member := range []string{"first member", "second member"} {
+		second = int64(stream.Len())
+		zw := gzip.NewWriter(&stream)
+		zw.Write([]byte(member))
+		zw.Close()
+	}
+
+	mr, err := NewReader(bytes.NewReader(stream.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(LayerReader(gzipLayer)); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := mr.Seek(second, io.SeekStart); err != nil || pos != second {
+		t.Fatalf("Seek = %d, %v", pos, err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "second member" {
+		t.Fatalf("ReadAll after Seek = %q, %v", got, err)
+	}
+
+	_, err = mr.Seek(1, io.SeekStart)
+	var le *LayerError
+	if !errors.As(err, &le) || le.Index != 1 {
+		t.Fatalf("Seek into the middle of a member = %v, want a LayerError for layer 1", err)
+	}
+}
+
+func TestSeekNeedsSeekableBase(t *testing.T) {
+	mr, err := NewReader(iotest.OneByteReader(strings.NewReader("x")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Seek(0, io.SeekStart); err != ErrNotSeekable {
+		t.Fatalf("Seek = %v, want %v", err, ErrNotSeekable)
+	}
+}
+
+func TestClosedMultiReader(t *testing.T) {
+	base := &closeCounter{}
+	base.WriteString("unread")
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := mr.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.closed != 1 {
+		t.Fatalf("base closed %d times, want 1", base.closed)
+	}
+
+	_, readErr := mr.Read(make([]byte, 4))
+	_, peekErr := mr.Peek(1)
+	for name, err := range map[string]error{
+		"Read":      readErr,
+		"Peek":      peekErr,
+		"AddReader": mr.AddReader(&PassthroughReader{}),
+	} {
+		if err != ErrChainClosed {
+			t.Errorf("%s after Close = %v, want %v", name, err, ErrChainClosed)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package iochain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	_ ResettableWriter = (*MultiTargetWriter)(nil)
+	_ Finisher         = (*MultiTargetWriter)(nil)
+)
+
+// TargetPolicy selects how MultiTargetWriter handles a failed write to a side target.
+// Short writes count as failures with io.ErrShortWrite.
+type TargetPolicy int
+
+const (
+	// TargetAbort fails the Write with the target's error.
+	TargetAbort TargetPolicy = iota
+	// TargetDrop stops writing to the target and continues with the others.
+	TargetDrop
+)
+
+// Target is a side writer of a MultiTargetWriter with its failure policy.
+type Target struct {
+	W      io.Writer
+	Policy TargetPolicy
+}
+
+// MultiTargetWriter forwards every write to its downstream writer and duplicates the
+// bytes the downstream accepted to several side targets, such as a file, a hash and a
+// network connection.
+type MultiTargetWriter struct {
+	w       io.Writer
+	targets []Target
+	dropped []bool
+	errs    []error // failures of dropped targets
+}
+
+// NewMultiTargetWriter creates a MultiTargetWriter duplicating to targets. The downstream
+// writer is set with Reset, typically by StackWriter.AddWriter.
+func NewMultiTargetWriter(targets ...Target) *MultiTargetWriter {
+	return &MultiTargetWriter{targets: targets, dropped: make([]bool, len(targets))}
+}
+
+// Write writes p downstream and then to each remaining target. A failing TargetAbort
+// target fails the Write; the bytes count reported is still the downstream's.
+func (m *MultiTargetWriter) Write(p []byte) (int, error) {
+	if m.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := m.w.Write(p)
+	if n == 0 {
+		return n, err
+	}
+
+	var errs []error
+	for i, t := range m.targets {
+		if m.dropped[i] {
+			continue
+		}
+		nw, werr := t.W.Write(p[:n])
+		if werr == nil && nw < n {
+			werr = io.ErrShortWrite
+		}
+		if werr == nil {
+			continue
+		}
+		werr = fmt.Errorf("target %d: %w", i, werr)
+		if t.Policy == TargetDrop {
+			m.dropped[i] = true
+			m.errs = append(m.errs, werr)
+			continue
+		}
+		errs = append(errs, werr)
+	}
+	if err == nil {
+		err = errors.Join(errs...)
+	}
+	return n, err
+}
+
+// Dropped reports whether target i has been dropped after a failure.
+func (m *MultiTargetWriter) Dropped(i int) bool {
+	return m.dropped[i]
+}
+
+// Err returns the failures that caused targets to be dropped, joined, or nil.
+func (m *MultiTargetWriter) Err() error {
+	return errors.Join(m.errs...)
+}
+
+// Reset changes the downstream writer.
+func (m *MultiTargetWriter) Reset(w io.Writer) error {
+	m.w = w
+	return nil
+}
+
+// Finish does nothing: a rotation keeps the targets open.
+func (m *MultiTargetWriter) Finish() error {
+	return nil
+}
+
+// Close closes every target that implements io.Closer, including dropped ones. The
+// downstream writer is not closed. All errors are returned, joined.
+func (m *MultiTargetWriter) Close() error {
+	var errs []error
+	for _, t := range m.targets {
+		if closer, ok := t.W.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
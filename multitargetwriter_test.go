@@ -0,0 +1,47 @@
+package iochain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMultiTargetWriterPolicies(t *testing.T) {
+	errDrop := errors.New("drop me")
+	errAbort := errors.New("abort")
+	kept := &closeCounter{}
+
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := NewMultiTargetWriter(
+		Target{W: kept},
+		Target{W: failWriter{errDrop}, Policy: TargetDrop},
+	)
+	if err := sw.AddWriter(mt); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"one ", "two"} {
+		if _, err := sw.Write([]byte(p)); err != nil {
+			t.Fatalf("Write(%q) = %v", p, err)
+		}
+	}
+	if out.String() != "one two" || kept.String() != "one two" {
+		t.Fatalf("downstream %q, kept target %q", out.String(), kept.String())
+	}
+	if !mt.Dropped(1) || mt.Dropped(0) || !errors.Is(mt.Err(), errDrop) {
+		t.Fatalf("Dropped = %v, %v, Err = %v", mt.Dropped(0), mt.Dropped(1), mt.Err())
+	}
+	if err := mt.Close(); err != nil || kept.closed != 1 {
+		t.Fatalf("Close = %v, kept target closed %d times", err, kept.closed)
+	}
+
+	mt = NewMultiTargetWriter(Target{W: failWriter{errAbort}})
+	mt.Reset(&out)
+	if n, err := mt.Write([]byte("abc")); n != 3 || !errors.Is(err, errAbort) {
+		t.Fatalf("Write with a failing TargetAbort target = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,82 @@
+package iochain
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Muxed streams are a sequence of frames:
+//
+//	uvarint(stream id) uvarint(length) data
+//
+// A frame of length 0 marks the end of its stream.
+const maxMuxFrame = 64 * 1024
+
+// ErrInvalidMuxFrame is returned by Demuxer for malformed frames.
+var ErrInvalidMuxFrame = errors.New("invalid mux frame")
+
+// Muxer interleaves several logical streams over one writer, typically a StackWriter.
+// Each write to a stream becomes one or more frames tagged with the stream ID.
+type Muxer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// NewMuxer creates a Muxer writing frames to w.
+func NewMuxer(w io.Writer) *Muxer {
+	return &Muxer{w: w}
+}
+
+// Stream returns a writer for the logical stream id. Closing it writes the end-of-stream
+// frame; it does not close the Muxer's writer. Streams may be written concurrently.
+func (m *Muxer) Stream(id uint64) io.WriteCloser {
+	return &muxStream{mux: m, id: id}
+}
+
+// writeFrame writes one frame with a single Write on the underlying writer.
+func (m *Muxer) writeFrame(id uint64, p []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buf = binary.AppendUvarint(m.buf[:0], id)
+	m.buf = binary.AppendUvarint(m.buf, uint64(len(p)))
+	m.buf = append(m.buf, p...)
+	n, err := m.w.Write(m.buf)
+	if err == nil && n < len(m.buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+type muxStream struct {
+	mux    *Muxer
+	id     uint64
+	closed bool
+}
+
+func (s *muxStream) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), maxMuxFrame)]
+		if err := s.mux.writeFrame(s.id, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (s *muxStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.mux.writeFrame(s.id, nil)
+}
@@ -0,0 +1,59 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMuxerRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewMuxer(sw)
+	data, index := mux.Stream(1), mux.Stream(2)
+	big := strings.Repeat("d", maxMuxFrame+100)
+	io.WriteString(data, "data ")
+	io.WriteString(index, "index")
+	io.WriteString(data, big)
+	if err := index.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := data.Write([]byte("late")); err != io.ErrClosedPipe {
+		t.Fatalf("Write after Close = %v, want %v", err, io.ErrClosedPipe)
+	}
+
+	// Reading stream 2 first buffers the frames of stream 1 met on the way.
+	demux := NewDemuxer(bytes.NewReader(out.Bytes()))
+	for _, tc := range []struct {
+		id   uint64
+		want string
+	}{{2, "index"}, {1, "data " + big}} {
+		got, err := io.ReadAll(demux.Stream(tc.id))
+		if err != nil || string(got) != tc.want {
+			t.Fatalf("stream %d = %d bytes, %v, want %d bytes", tc.id, len(got), err, len(tc.want))
+		}
+	}
+}
+
+func TestDemuxerTruncatedInput(t *testing.T) {
+	var out bytes.Buffer
+	s := NewMuxer(&out).Stream(7)
+	io.WriteString(s, "never closed")
+
+	got, err := io.ReadAll(NewDemuxer(&out).Stream(7))
+	if err != io.ErrUnexpectedEOF || string(got) != "never closed" {
+		t.Fatalf("ReadAll = %q, %v, want the data and io.ErrUnexpectedEOF", got, err)
+	}
+
+	bad := []byte{1, 0xff, 0xff, 0x7f} // length far above the frame limit
+	if _, err := NewDemuxer(bytes.NewReader(bad)).Stream(1).Read(make([]byte, 8)); err != ErrInvalidMuxFrame {
+		t.Fatalf("oversized frame: error = %v, want %v", err, ErrInvalidMuxFrame)
+	}
+}
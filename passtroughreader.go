@@ -2,9 +2,12 @@ package iochain
 
 import "io"
 
+var _ ResettableReader = (*PassthroughReader)(nil)
+
 // PassthroughReader wraps another io.Reader and just passes reads through.
 type PassthroughReader struct {
-	src io.Reader
+	src   io.Reader
+	owned bool // whether src came from the constructor and must be closed
 }
 
 // Read just delegates the call to the underlying reader.
@@ -12,13 +15,25 @@ func (r *PassthroughReader) Read(p []byte) (int, error) {
 	return r.src.Read(p)
 }
 
+// Reset swaps the underlying reader.
+func (r *PassthroughReader) Reset(src io.Reader) error {
+	r.src = src
+	r.owned = false
+	return nil
+}
+
+// Close closes the underlying reader if it was passed to NewPasstroughReader and
+// implements io.Closer. A reader set with Reset is owned by whoever supplied it, which
+// is the MultiReader when the PassthroughReader is part of a chain, and is not closed.
 func (r *PassthroughReader) Close() error {
-	if closer, ok := r.src.(io.Closer); ok {
+	if closer, ok := r.src.(io.Closer); ok && r.owned {
+		r.owned = false
 		return closer.Close()
 	}
 	return nil
 }
 
+// NewPasstroughReader creates a PassthroughReader reading from src, which it closes on Close.
 func NewPasstroughReader(src io.Reader) *PassthroughReader {
-	return &PassthroughReader{src: src}
+	return &PassthroughReader{src: src, owned: true}
 }
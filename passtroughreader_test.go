@@ -0,0 +1,24 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEmptyPassthroughReaderInChain(t *testing.T) {
+	mr, err := NewReader(strings.NewReader("through"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.AddReader(&PassthroughReader{}); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Depth() != 2 {
+		t.Fatalf("Depth = %d, want 2", mr.Depth())
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "through" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,61 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+)
+
+// PrependReader returns all of a header reader followed by a body reader.
+// When pushed onto a MultiReader, Reset replaces the body with the previous layer.
+type PrependReader struct {
+	header     io.Reader
+	body       io.Reader
+	bodyOwned  bool // whether body came from the constructor and must be closed
+	headerDone bool
+}
+
+// NewPrependReader creates a PrependReader that yields header and then body.
+func NewPrependReader(header, body io.Reader) *PrependReader {
+	return &PrependReader{header: header, body: body, bodyOwned: true}
+}
+
+// Read returns header bytes until the header is exhausted, then body bytes.
+func (r *PrependReader) Read(p []byte) (int, error) {
+	if !r.headerDone && r.header != nil {
+		n, err := r.header.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		r.headerDone = true
+		if n > 0 {
+			return n, nil
+		}
+	}
+	if r.body == nil {
+		return 0, io.EOF
+	}
+	return r.body.Read(p)
+}
+
+// Reset replaces the body reader. Header bytes that have not been read yet are kept.
+func (r *PrependReader) Reset(body io.Reader) error {
+	r.body = body
+	r.bodyOwned = false
+	return nil
+}
+
+// Close closes the header and, if it was passed to NewPrependReader, the body, when
+// they implement io.Closer. A body set with Reset is owned by whoever supplied it,
+// which is the MultiReader when the PrependReader is part of a chain, and is not closed.
+func (r *PrependReader) Close() error {
+	var errs []error
+	if closer, ok := r.header.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+		r.header = nil
+	}
+	if closer, ok := r.body.(io.Closer); ok && r.bodyOwned {
+		errs = append(errs, closer.Close())
+		r.bodyOwned = false
+	}
+	return errors.Join(errs...)
+}
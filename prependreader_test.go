@@ -0,0 +1,50 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPrependReader(t *testing.T) {
+	header, body := &closeCounter{}, &closeCounter{}
+	header.WriteString("header,")
+	body.WriteString("body")
+	r := NewPrependReader(header, iotest.HalfReader(body))
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || string(got) != "header,body" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+
+	r = NewPrependReader(header, body)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if header.closed != 1 || body.closed != 1 {
+		t.Fatalf("closed header %d and body %d times, want 1 and 1", header.closed, body.closed)
+	}
+}
+
+func TestPrependReaderInChain(t *testing.T) {
+	base := &closeCounter{}
+	base.WriteString("stream")
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := NewPrependReader(strings.NewReader("preamble\n"), nil)
+	if err := mr.AddReader(pr); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "preamble\nstream" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if base.closed != 0 {
+		t.Fatal("PrependReader closed the layer below it")
+	}
+}
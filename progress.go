@@ -0,0 +1,113 @@
+package iochain
+
+import "time"
+
+// Progress is the cumulative state of a chain reported to OnProgress callbacks.
+type Progress struct {
+	Bytes   int64         // bytes written to a StackWriter or read from a MultiReader
+	Elapsed time.Duration // time since OnProgress was called
+}
+
+// ETA estimates the time left until total bytes are reached at the average rate so far.
+// It returns 0 once total is reached and -1 while no rate is known yet.
+func (p Progress) ETA(total int64) time.Duration {
+	if p.Bytes >= total {
+		return 0
+	}
+	if p.Bytes <= 0 || p.Elapsed <= 0 {
+		return -1
+	}
+	rate := float64(p.Bytes) / p.Elapsed.Seconds()
+	return time.Duration(float64(total-p.Bytes) / rate * float64(time.Second))
+}
+
+// progressTracker invokes a callback when enough time or bytes have passed since the
+// last report.
+type progressTracker struct {
+	fn        func(Progress)
+	interval  time.Duration
+	every     int64
+	start     time.Time
+	lastTime  time.Time
+	lastBytes int64
+}
+
+func newProgressTracker(fn func(Progress), interval time.Duration, everyBytes int64, total int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{fn: fn, interval: interval, every: everyBytes, start: now, lastTime: now, lastBytes: total}
+}
+
+// update reports total if a threshold has been crossed since the last report.
+func (t *progressTracker) update(total int64) {
+	now := time.Now()
+	due := t.interval <= 0 && t.every <= 0
+	if t.interval > 0 && now.Sub(t.lastTime) >= t.interval {
+		due = true
+	}
+	if t.every > 0 && total-t.lastBytes >= t.every {
+		due = true
+	}
+	if due && total != t.lastBytes {
+		t.report(total, now)
+	}
+}
+
+// finish reports total if it has not been reported yet.
+func (t *progressTracker) finish(total int64) {
+	if total != t.lastBytes {
+		t.report(total, time.Now())
+	}
+}
+
+func (t *progressTracker) report(total int64, now time.Time) {
+	t.lastTime, t.lastBytes = now, total
+	t.fn(Progress{Bytes: total, Elapsed: now.Sub(t.start)})
+}
+
+// OnProgress calls fn with the bytes written so far whenever interval has passed or
+// everyBytes more bytes have been written since the last call; a zero threshold is
+// ignored, and with both zero fn is called after every write. A final call is made on
+// Close if needed. fn runs with the stack locked and must not use the StackWriter.
+// A nil fn removes the callback.
+func (m *StackWriter) OnProgress(interval time.Duration, everyBytes int64, fn func(Progress)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.progress = nil
+	if fn != nil {
+		m.progress = newProgressTracker(fn, interval, everyBytes, m.written.Load())
+	}
+}
+
+// addWritten counts n bytes accepted by the top writer. The lock must be held.
+func (m *StackWriter) addWritten(n int64) {
+	total := m.written.Add(n)
+	if m.progress != nil {
+		m.progress.update(total)
+	}
+}
+
+// OnProgress calls fn with the bytes read so far whenever interval has passed or
+// everyBytes more bytes have been read since the last call; a zero threshold is
+// ignored, and with both zero fn is called after every read. A final call is made on
+// Close if needed. fn runs with the chain locked and must not use the MultiReader.
+// A nil fn removes the callback.
+func (m *MultiReader) OnProgress(interval time.Duration, everyBytes int64, fn func(Progress)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.progress = nil
+	if fn != nil {
+		m.progress = newProgressTracker(fn, interval, everyBytes, m.read.Load())
+	}
+}
+
+// addRead counts n bytes returned from the chain and returns the new total. The lock
+// must be held.
+func (m *MultiReader) addRead(n int64) int64 {
+	total := m.read.Add(n)
+	if m.progress != nil {
+		m.progress.update(total)
+	}
+	return total
+}
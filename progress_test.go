@@ -0,0 +1,64 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestStackWriterOnProgressByteThreshold(t *testing.T) {
+	sw, err := NewStackWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []int64
+	sw.OnProgress(0, 10, func(p Progress) { reported = append(reported, p.Bytes) })
+	for range 5 {
+		if _, err := sw.Write([]byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	// Reports after crossing 10 and 20 bytes; the 20 bytes are not reported again on Close.
+	if len(reported) != 2 || reported[0] != 12 || reported[1] != 20 {
+		t.Fatalf("reported %v, want [12 20]", reported)
+	}
+}
+
+func TestMultiReaderOnProgressFinalReport(t *testing.T) {
+	mr, err := NewReader(iotest.OneByteReader(strings.NewReader("progress")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []int64
+	mr.OnProgress(time.Hour, 0, func(p Progress) { reported = append(reported, p.Bytes) })
+	if _, err := io.ReadAll(mr); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 0 {
+		t.Fatalf("reported %v before the interval passed", reported)
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || reported[0] != 8 {
+		t.Fatalf("reported %v on Close, want [8]", reported)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{Bytes: 250, Elapsed: time.Second}
+	if eta := p.ETA(1000); eta != 3*time.Second {
+		t.Fatalf("ETA = %v, want 3s", eta)
+	}
+	if eta := p.ETA(250); eta != 0 {
+		t.Fatalf("ETA at the total = %v, want 0", eta)
+	}
+	if eta := (Progress{}).ETA(1000); eta != -1 {
+		t.Fatalf("ETA without a rate = %v, want -1", eta)
+	}
+}
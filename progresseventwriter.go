@@ -0,0 +1,94 @@
+package iochain
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressEvent reports the number of bytes written so far.
+type ProgressEvent struct {
+	BytesWritten int64
+	Timestamp    time.Time
+}
+
+// ProgressEventWriter passes writes through to w and publishes progress events on a channel.
+// Events are sent at most once per interval and never block writes: if the channel is full
+// the event is dropped, and every later Write tries again with the current count until one
+// is delivered, so no stale count is queued behind it.
+type ProgressEventWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	ch       chan<- ProgressEvent
+	interval time.Duration
+	written  int64
+	last     time.Time
+	closed   bool
+}
+
+// NewProgressEventWriter creates a ProgressEventWriter writing to w and sending events to ch.
+// A nil ch disables the events.
+func NewProgressEventWriter(w io.Writer, ch chan<- ProgressEvent, interval time.Duration) *ProgressEventWriter {
+	return &ProgressEventWriter{w: w, ch: ch, interval: interval}
+}
+
+// Write writes p to the underlying writer and publishes an event if the interval has elapsed.
+func (pw *ProgressEventWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+
+	now := time.Now()
+	if now.Sub(pw.last) >= pw.interval {
+		pw.send(now)
+	}
+	return n, err
+}
+
+// Reset changes the underlying writer. The byte count is preserved.
+func (pw *ProgressEventWriter) Reset(w io.Writer) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.w = w
+	return nil
+}
+
+// Finish publishes an event without closing the event channel.
+func (pw *ProgressEventWriter) Finish() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.send(time.Now())
+	return nil
+}
+
+// Close publishes a final event and closes the event channel, if there is one.
+// The underlying writer is not closed.
+func (pw *ProgressEventWriter) Close() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.closed {
+		return nil
+	}
+	pw.send(time.Now())
+	pw.closed = true
+	if pw.ch != nil {
+		close(pw.ch)
+	}
+	return nil
+}
+
+// send publishes the current count without blocking. A dropped event leaves pw.last
+// unchanged, so the next Write retries. The caller must hold pw.mu.
+func (pw *ProgressEventWriter) send(now time.Time) {
+	if pw.closed || pw.ch == nil {
+		return
+	}
+	select {
+	case pw.ch <- ProgressEvent{BytesWritten: pw.written, Timestamp: now}:
+		pw.last = now
+	default:
+	}
+}
@@ -0,0 +1,49 @@
+package iochain
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestProgressEventWriterRetriesDroppedEvents(t *testing.T) {
+	ch := make(chan ProgressEvent, 1)
+	var send chan<- ProgressEvent = ch
+	pw := NewProgressEventWriter(io.Discard, send, time.Hour)
+
+	ch <- ProgressEvent{} // a consumer that has fallen behind
+	pw.Write([]byte("a"))
+	<-ch
+
+	// The dropped event is retried with the current count despite the interval.
+	pw.Write([]byte("bb"))
+	if ev := <-ch; ev.BytesWritten != 3 {
+		t.Fatalf("retried event reports %d bytes, want 3", ev.BytesWritten)
+	}
+	pw.Write([]byte("ccc"))
+	select {
+	case ev := <-ch:
+		t.Fatalf("event %+v sent before the interval elapsed", ev)
+	default:
+	}
+
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-ch; ev.BytesWritten != 6 {
+		t.Fatalf("final event reports %d bytes, want 6", ev.BytesWritten)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after Close")
+	}
+}
+
+func TestProgressEventWriterNilChannel(t *testing.T) {
+	pw := NewProgressEventWriter(io.Discard, nil, 0)
+	if _, err := pw.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package iochain
+
+import (
+	"errors"
+	"time"
+)
+
+// rateLimiter is a token bucket holding up to burst bytes and refilled at rate bytes per
+// second. Tokens may go negative: the caller then sleeps until the debt is repaid.
+type rateLimiter struct {
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec float64, burst int) (*rateLimiter, error) {
+	if bytesPerSec <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+	if burst <= 0 {
+		return nil, errors.New("burst must be positive")
+	}
+	return &rateLimiter{rate: bytesPerSec, burst: burst, tokens: float64(burst), last: time.Now()}, nil
+}
+
+// wait takes n tokens, sleeping as long as needed to stay within the rate.
+func (l *rateLimiter) wait(n int) {
+	now := time.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		time.Sleep(time.Duration(-l.tokens / l.rate * float64(time.Second)))
+	}
+}
@@ -0,0 +1,52 @@
+package iochain
+
+import (
+	"io"
+	"sync"
+)
+
+var _ ResettableReader = (*RateLimitReader)(nil)
+
+// RateLimitReader throttles the bytes read from its source with a token bucket. Each Read
+// asks the source for at most burst bytes.
+type RateLimitReader struct {
+	mu      sync.Mutex
+	src     io.Reader
+	limiter *rateLimiter
+}
+
+// NewRateLimitReader creates a RateLimitReader allowing bytesPerSec on average and up to
+// burst bytes at once. The source is set with Reset, typically by MultiReader.AddReader.
+func NewRateLimitReader(bytesPerSec float64, burst int) (*RateLimitReader, error) {
+	limiter, err := newRateLimiter(bytesPerSec, burst)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimitReader{limiter: limiter}, nil
+}
+
+// Read reads up to burst bytes from the source, then waits as needed to stay within the rate.
+func (r *RateLimitReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.src == nil {
+		return 0, io.EOF
+	}
+	if len(p) > r.limiter.burst {
+		p = p[:r.limiter.burst]
+	}
+	n, err := r.src.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// Reset changes the source. The token bucket is kept.
+func (r *RateLimitReader) Reset(src io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.src = src
+	return nil
+}
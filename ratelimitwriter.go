@@ -0,0 +1,61 @@
+package iochain
+
+import (
+	"io"
+	"sync"
+)
+
+var _ ResettableWriter = (*RateLimitWriter)(nil)
+
+// RateLimitWriter throttles the bytes written to its downstream writer with a token
+// bucket. Writes larger than the burst are split so no single downstream write exceeds it.
+type RateLimitWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+// NewRateLimitWriter creates a RateLimitWriter allowing bytesPerSec on average and up to
+// burst bytes at once. The downstream writer is set with Reset, typically by
+// StackWriter.AddWriter.
+func NewRateLimitWriter(bytesPerSec float64, burst int) (*RateLimitWriter, error) {
+	limiter, err := newRateLimiter(bytesPerSec, burst)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimitWriter{limiter: limiter}, nil
+}
+
+// Write writes p downstream in chunks of at most burst bytes, waiting before each one
+// as needed to stay within the rate.
+func (r *RateLimitWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), r.limiter.burst)]
+		r.limiter.wait(len(chunk))
+		n, err := r.w.Write(chunk)
+		written += n
+		if err == nil && n < len(chunk) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Reset changes the downstream writer. The token bucket is kept.
+func (r *RateLimitWriter) Reset(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w = w
+	return nil
+}
@@ -0,0 +1,78 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkRecorder records the largest write it receives.
+type chunkRecorder struct {
+	bytes.Buffer
+	largest int
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.largest = max(c.largest, len(p))
+	return c.Buffer.Write(p)
+}
+
+func TestRateLimitWriterThrottles(t *testing.T) {
+	rw, err := NewRateLimitWriter(10000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &chunkRecorder{}
+	rw.Reset(out)
+
+	start := time.Now()
+	if n, err := rw.Write(make([]byte, 3000)); n != 3000 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	// The burst goes out at once; the other 2000 bytes take 0.2s at 10000 bytes/s.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("writing 3000 bytes took %v, want about 200ms", elapsed)
+	}
+	if out.Len() != 3000 || out.largest > 1000 {
+		t.Fatalf("downstream got %d bytes in writes of up to %d, want 3000 in writes of up to 1000", out.Len(), out.largest)
+	}
+}
+
+func TestRateLimitReaderThrottles(t *testing.T) {
+	rr, err := NewRateLimitReader(10000, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Reset(strings.NewReader(strings.Repeat("r", 2500)))
+
+	start := time.Now()
+	buf := make([]byte, 4096)
+	total := 0
+	for {
+		n, err := rr.Read(buf)
+		if n > 500 {
+			t.Fatalf("Read returned %d bytes, more than the burst", n)
+		}
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); total != 2500 || elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("read %d bytes in %v, want 2500 in about 200ms", total, elapsed)
+	}
+}
+
+func TestRateLimitRejectsInvalidSettings(t *testing.T) {
+	if _, err := NewRateLimitWriter(0, 10); err == nil {
+		t.Error("NewRateLimitWriter accepted a zero rate")
+	}
+	if _, err := NewRateLimitReader(10, 0); err == nil {
+		t.Error("NewRateLimitReader accepted a zero burst")
+	}
+}
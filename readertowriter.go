@@ -1,37 +1,186 @@
 package iochain
 
-import "io"
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// WriteErrorPolicy selects how ReaderToWriter handles failed writes to its target.
+// Short writes count as failures with io.ErrShortWrite.
+type WriteErrorPolicy int
+
+const (
+	// WriteErrorIgnore keeps reading; Err reports the last failure.
+	WriteErrorIgnore WriteErrorPolicy = iota
+	// WriteErrorFailFast returns the failure from the Read that hit it and from every
+	// later Read.
+	WriteErrorFailFast
+	// WriteErrorCollect keeps reading; Err reports all failures, joined.
+	WriteErrorCollect
+)
 
 // ReaderToWriter is a type that links an io.Reader source to an io.Writer target for data streaming and copying.
 // It and writes data to the target every time a read is performed.
 // The target writer can optionally implement io.Closer for resource cleanup upon closure.
+//
+// MirrorLimit and MirrorEvery restrict mirroring to a sample of the stream, and
+// SetBuffered decouples reads from a slow target. Set them before the first Read.
 type ReaderToWriter struct {
-	src    io.Reader
-	target io.Writer
+	// MirrorLimit mirrors only the first MirrorLimit bytes read; 0 means no limit.
+	MirrorLimit int64
+	// MirrorEvery mirrors only every MirrorEvery-th chunk read, starting with the
+	// first; 0 or 1 mirrors every chunk.
+	MirrorEvery int
+
+	src          io.Reader
+	target       io.Writer
+	policy       WriteErrorPolicy
+	onWriteError func(error) error
+	err          error   // returned by all Reads once set
+	writeErrs    []error // target write failures, only the last one unless collecting
+
+	counted  *countingWriter // target, counting the bytes it accepts; set by the constructors
+	async    *AsyncWriter    // queue in front of counted, nil unless buffered
+	offered  int64           // bytes handed to the target, for MirrorLimit
+	chunks   int64           // chunks read, for MirrorEvery
+	bufQueue int
+}
+
+// countingWriter counts the bytes accepted by w.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
 }
 
 // NewReaderToWriter creates a new ReaderToWriter instance with the specified io.Writer as the target destination.
+// Errors writing to the target are ignored.
 func NewReaderToWriter(w io.Writer) *ReaderToWriter {
-	return &ReaderToWriter{target: w}
+	return &ReaderToWriter{target: w, counted: &countingWriter{w: w}}
+}
+
+// NewReaderToWriterWithPolicy creates a ReaderToWriter that reports target write failures,
+// including short writes as io.ErrShortWrite, to onWriteError. If the callback returns a
+// non-nil error, every subsequent Read returns it.
+func NewReaderToWriterWithPolicy(w io.Writer, onWriteError func(error) error) *ReaderToWriter {
+	return &ReaderToWriter{target: w, onWriteError: onWriteError, counted: &countingWriter{w: w}}
+}
+
+// NewReaderToWriterWithErrorPolicy creates a ReaderToWriter handling target write
+// failures according to policy.
+func NewReaderToWriterWithErrorPolicy(w io.Writer, policy WriteErrorPolicy) *ReaderToWriter {
+	return &ReaderToWriter{target: w, policy: policy, counted: &countingWriter{w: w}}
+}
+
+// SetBuffered queues mirrored chunks for up to queueSize pending writes, written to the
+// target by a background goroutine, so that a slow target does not stall reads. When
+// the queue is full the chunk is dropped and reported as a write failure with
+// ErrQueueFull; failures of the target are reported on a later Read or on Close.
+// A queueSize of 0 writes synchronously, the default. It must be called before the
+// first Read.
+func (r *ReaderToWriter) SetBuffered(queueSize int) {
+	r.bufQueue = queueSize
+}
+
+// Written returns the number of bytes the target has accepted so far. With
+// SetBuffered, bytes still queued are not included.
+func (r *ReaderToWriter) Written() int64 {
+	if r.counted == nil {
+		return 0
+	}
+	return r.counted.n.Load()
+}
+
+// side returns the writer mirrored chunks are written to, creating the queue on first
+// use when SetBuffered was called.
+func (r *ReaderToWriter) side() io.Writer {
+	if r.async == nil && r.bufQueue > 0 {
+		r.async = NewAsyncWriter(r.counted, r.bufQueue)
+		r.async.FailWhenFull = true
+	}
+	if r.async != nil {
+		return r.async
+	}
+	return r.counted
+}
+
+// sample returns the part of the chunk p that should be mirrored, possibly empty.
+// Empty reads do not count as chunks.
+func (r *ReaderToWriter) sample(p []byte) []byte {
+	if len(p) == 0 {
+		return nil
+	}
+	r.chunks++
+	if r.MirrorEvery > 1 && (r.chunks-1)%int64(r.MirrorEvery) != 0 {
+		return nil
+	}
+	if r.MirrorLimit > 0 {
+		remaining := r.MirrorLimit - r.offered
+		if remaining <= 0 {
+			return nil
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	r.offered += int64(len(p))
+	return p
 }
 
 func (r *ReaderToWriter) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
 	n, err := r.src.Read(p)
-	if n > 0 {
-		_, _ = r.target.Write(p[:n]) // write copy, ignore write error
+	if chunk := r.sample(p[:n]); len(chunk) > 0 {
+		nw, werr := r.side().Write(chunk)
+		if werr == nil && nw < len(chunk) {
+			werr = io.ErrShortWrite
+		}
+		if werr != nil {
+			if r.policy == WriteErrorCollect {
+				r.writeErrs = append(r.writeErrs, werr)
+			} else {
+				r.writeErrs = append(r.writeErrs[:0], werr)
+			}
+			switch {
+			case r.onWriteError != nil:
+				r.err = r.onWriteError(werr)
+			case r.policy == WriteErrorFailFast:
+				r.err = werr
+				return n, werr
+			}
+		}
 	}
 	return n, err
 }
 
+// Err returns the target write failures seen so far: all of them, joined, under
+// WriteErrorCollect, otherwise the last one. It returns nil if every write succeeded.
+func (r *ReaderToWriter) Err() error {
+	return errors.Join(r.writeErrs...)
+}
+
 func (r *ReaderToWriter) Reset(src io.Reader) error {
 	r.src = src
 	return nil
 }
 
-// Close closes the underlying writer if it implements io.Closer.
+// Close waits for buffered chunks to be written and then closes the target writer if
+// it implements io.Closer. The source is not closed.
 func (r *ReaderToWriter) Close() error {
+	var errs []error
+	if r.async != nil {
+		errs = append(errs, r.async.Close())
+	}
 	if closer, ok := r.target.(io.Closer); ok {
-		return closer.Close()
+		errs = append(errs, closer.Close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
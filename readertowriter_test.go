@@ -0,0 +1,134 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// onceCloser is a reader or writer whose Close panics when called a second time.
+type onceCloser struct {
+	io.Reader
+	io.Writer
+	closed int
+}
+
+func (c *onceCloser) Close() error {
+	c.closed++
+	if c.closed > 1 {
+		panic("closed twice")
+	}
+	return nil
+}
+
+func TestMultiReaderClosesEachReaderOnce(t *testing.T) {
+	base := &onceCloser{Reader: strings.NewReader("data")}
+	target := &onceCloser{Writer: io.Discard}
+
+	mr, err := NewReader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []ResettableReader{
+		NewReaderToWriter(target),
+		NewPasstroughReader(nil),
+		NewPrependReader(strings.NewReader("hdr:"), nil),
+	} {
+		if err := mr.AddReader(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "hdr:data" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+
+	if err := mr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if base.closed != 1 || target.closed != 1 {
+		t.Fatalf("base closed %d times, target %d times; want 1 each", base.closed, target.closed)
+	}
+}
+
+func TestConstructorReadersAreClosed(t *testing.T) {
+	src := &onceCloser{Reader: new(bytes.Buffer)}
+	pr := NewPasstroughReader(src)
+	if err := pr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if src.closed != 1 {
+		t.Fatalf("PassthroughReader closed its source %d times, want 1", src.closed)
+	}
+
+	header := &onceCloser{Reader: new(bytes.Buffer)}
+	body := &onceCloser{Reader: new(bytes.Buffer)}
+	pp := NewPrependReader(header, body)
+	if err := pp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if header.closed != 1 || body.closed != 1 {
+		t.Fatalf("PrependReader closed header %d times, body %d times; want 1 each", header.closed, body.closed)
+	}
+}
+
+// stutterReader returns an empty read before every chunk of data.
+type stutterReader struct {
+	chunks []string
+	empty  bool
+}
+
+func (s *stutterReader) Read(p []byte) (int, error) {
+	if len(s.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if s.empty = !s.empty; s.empty {
+		return 0, nil
+	}
+	n := copy(p, s.chunks[0])
+	s.chunks = s.chunks[1:]
+	return n, nil
+}
+
+func TestMirrorEveryIgnoresEmptyReads(t *testing.T) {
+	var side bytes.Buffer
+	rw := NewReaderToWriter(&side)
+	rw.MirrorEvery = 2
+	rw.Reset(&stutterReader{chunks: []string{"a", "b", "c", "d", "e"}})
+	if _, err := io.ReadAll(rw); err != nil {
+		t.Fatal(err)
+	}
+	if side.String() != "ace" {
+		t.Fatalf("mirrored %q, want %q", side.String(), "ace")
+	}
+}
+
+func TestWrittenConcurrentWithReads(t *testing.T) {
+	rw := NewReaderToWriter(io.Discard)
+	rw.Reset(strings.NewReader(strings.Repeat("x", 4096)))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			rw.Written()
+		}
+	}()
+	if _, err := io.Copy(io.Discard, rw); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if rw.Written() != 4096 {
+		t.Fatalf("Written = %d, want 4096", rw.Written())
+	}
+}
@@ -0,0 +1,400 @@
+package iochain
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Options holds the parameters of a chain stage, e.g. level=9 in "gzip:level=9".
+type Options map[string]string
+
+// String returns the value of key, or def if it is not set.
+func (o Options) String(key, def string) string {
+	if v, ok := o[key]; ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the value of key parsed as an integer, or def if it is not set.
+func (o Options) Int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("option %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// Format creates the layers of a named chain stage. Either constructor may be nil if the
+// format only supports one direction.
+type Format struct {
+	// Keys lists the options the format accepts in either direction. A spec with any
+	// other option is rejected, so a misspelt key is not silently ignored.
+	Keys []string
+
+	NewReader func(opts Options) (ResettableReader, error)
+	NewWriter func(opts Options) (ResettableWriter, error)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = make(map[string]Format)
+)
+
+// RegisterFormat makes a format available to BuildReaderChain and BuildWriterChain.
+// It panics if name is empty, contains spec syntax characters or is already registered.
+func RegisterFormat(name string, f Format) {
+	if name == "" || strings.ContainsAny(name, "|:,= ") {
+		panic("iochain: invalid format name " + strconv.Quote(name))
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if _, dup := formats[name]; dup {
+		panic("iochain: RegisterFormat called twice for " + name)
+	}
+	formats[name] = f
+}
+
+// Formats returns the names of the registered formats in sorted order.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupFormat(name string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	f, ok := formats[name]
+	return f, ok
+}
+
+// LayerSpec describes one stage of a chain: a registered format name and its options.
+// Its text form is "name[:key=value,...]", so lists of specs can be decoded directly
+// from JSON or YAML strings such as ["gzip:level=6", "sha256"].
+//
+// Option values of the form "env:NAME" are replaced by the value of the environment
+// variable NAME when the chain is built, so secrets need not appear in configuration.
+type LayerSpec struct {
+	Name    string
+	Options Options
+}
+
+// ParseLayerSpec parses the text form of a LayerSpec.
+func ParseLayerSpec(text string) (LayerSpec, error) {
+	name, params, hasParams := strings.Cut(strings.TrimSpace(text), ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return LayerSpec{}, fmt.Errorf("empty stage in spec %q", text)
+	}
+
+	opts := Options{}
+	if hasParams {
+		for _, kv := range strings.Split(params, ",") {
+			key, value, ok := strings.Cut(kv, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return LayerSpec{}, fmt.Errorf("stage %q: invalid parameter %q, want key=value", name, kv)
+			}
+			if _, dup := opts[key]; dup {
+				return LayerSpec{}, fmt.Errorf("stage %q: duplicate parameter %q", name, key)
+			}
+			opts[key] = strings.TrimSpace(value)
+		}
+	}
+	return LayerSpec{Name: name, Options: opts}, nil
+}
+
+// String returns the text form of s, with options sorted by key.
+func (s LayerSpec) String() string {
+	keys := make([]string, 0, len(s.Options))
+	for key := range s.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.Name)
+	for i, key := range keys {
+		if i == 0 {
+			b.WriteByte(':')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(key + "=" + s.Options[key])
+	}
+	return b.String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s LayerSpec) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *LayerSpec) UnmarshalText(text []byte) error {
+	parsed, err := ParseLayerSpec(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// format returns the registered format of s, checking that it accepts the options of s.
+func (s LayerSpec) format() (Format, error) {
+	f, ok := lookupFormat(s.Name)
+	if !ok {
+		return Format{}, fmt.Errorf("unknown stage %q", s.Name)
+	}
+	for key := range s.Options {
+		if !slices.Contains(f.Keys, key) {
+			return Format{}, fmt.Errorf("stage %q: unknown option %q", s.Name, key)
+		}
+	}
+	return f, nil
+}
+
+// resolve returns the format of s and its options with env: references expanded.
+func (s LayerSpec) resolve() (Format, Options, error) {
+	f, err := s.format()
+	if err != nil {
+		return Format{}, nil, err
+	}
+	opts := make(Options, len(s.Options))
+	for key, value := range s.Options {
+		if name, ok := strings.CutPrefix(value, "env:"); ok {
+			if value, ok = os.LookupEnv(name); !ok {
+				return Format{}, nil, fmt.Errorf("stage %q: option %s: environment variable %s is not set", s.Name, key, name)
+			}
+		}
+		opts[key] = value
+	}
+	return f, opts, nil
+}
+
+// parseSpec parses a spec of the form "name[:key=value,...]|name...".
+// Stages are listed from the base outwards.
+func parseSpec(spec string) ([]LayerSpec, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var specs []LayerSpec
+	for _, part := range strings.Split(spec, "|") {
+		s, err := ParseLayerSpec(part)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.format(); err != nil {
+			return nil, err
+		}
+		specs = append(specs, s)
+	}
+	return specs, nil
+}
+
+// BuildReaderChain creates a MultiReader on base with the layers described by spec,
+// e.g. "base64|gzip" to decode base64 first and then decompress.
+// Stages are listed from the base outwards and must be registered with RegisterFormat.
+func BuildReaderChain(base io.Reader, spec string) (*MultiReader, error) {
+	specs, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return BuildReaderChainFromSpecs(base, specs)
+}
+
+// BuildReaderChainFromSpecs is like BuildReaderChain with the stages already parsed.
+// If a stage fails, the layers already added are closed; base is left open.
+func BuildReaderChainFromSpecs(base io.Reader, specs []LayerSpec) (*MultiReader, error) {
+	mr, err := NewReader(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range specs {
+		if err := addReaderStage(mr, s); err != nil {
+			return nil, errors.Join(err, mr.closeLayers())
+		}
+	}
+	return mr, nil
+}
+
+// addReaderStage adds the reader of stage s to mr. A reader that cannot be added is closed.
+func addReaderStage(mr *MultiReader, s LayerSpec) error {
+	f, opts, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	if f.NewReader == nil {
+		return fmt.Errorf("stage %q does not support reading", s.Name)
+	}
+	r, err := f.NewReader(opts)
+	if err != nil {
+		return fmt.Errorf("stage %q: %w", s.Name, err)
+	}
+	if err := mr.AddReader(r); err != nil {
+		return errors.Join(fmt.Errorf("stage %q: %w", s.Name, err), closeLayer(r))
+	}
+	return nil
+}
+
+// BuildWriterChain creates a StackWriter on base with the layers described by spec.
+// The same spec passed to BuildReaderChain reads the output back.
+func BuildWriterChain(base io.Writer, spec string) (*StackWriter, error) {
+	specs, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return BuildWriterChainFromSpecs(base, specs)
+}
+
+// BuildWriterChainFromSpecs is like BuildWriterChain with the stages already parsed.
+// If a stage fails, the layers already added are closed; base is left open.
+func BuildWriterChainFromSpecs(base io.Writer, specs []LayerSpec) (*StackWriter, error) {
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range specs {
+		if err := addWriterStage(sw, s); err != nil {
+			return nil, errors.Join(err, sw.closeLayers())
+		}
+	}
+	return sw, nil
+}
+
+// addWriterStage adds the writer of stage s to sw. A writer that cannot be added is closed.
+func addWriterStage(sw *StackWriter, s LayerSpec) error {
+	f, opts, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	if f.NewWriter == nil {
+		return fmt.Errorf("stage %q does not support writing", s.Name)
+	}
+	w, err := f.NewWriter(opts)
+	if err != nil {
+		return fmt.Errorf("stage %q: %w", s.Name, err)
+	}
+	if err := sw.AddWriter(w); err != nil {
+		return errors.Join(fmt.Errorf("stage %q: %w", s.Name, err), closeLayer(w))
+	}
+	return nil
+}
+
+// closeLayers closes the readers above the base of a chain that failed to build, from
+// the top down, and marks the chain closed. The base is not closed.
+func (m *MultiReader) closeLayers() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for i := len(m.readers) - 1; i >= 1; i-- {
+		if err := closeLayer(m.readers[i]); err != nil {
+			errs = append(errs, layerErr(i, m.readers[i], err))
+		}
+	}
+	m.readers = nil
+	m.closed = true
+	return errors.Join(errs...)
+}
+
+// closeLayers closes the writers above the base of a chain that failed to build, from
+// the top down, and marks the chain closed. The base is not closed.
+func (m *StackWriter) closeLayers() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for i := len(m.writers) - 1; i >= 1; i-- {
+		if err := closeLayer(m.writers[i]); err != nil {
+			errs = append(errs, layerErr(i, m.writers[i], err))
+		}
+	}
+	m.writers = nil
+	m.closed = true
+	return errors.Join(errs...)
+}
+
+// closeLayer closes l if it implements io.Closer.
+func closeLayer(l any) error {
+	if closer, ok := l.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func init() {
+	RegisterFormat("sha256", Format{
+		NewReader: func(Options) (ResettableReader, error) {
+			return NewChecksumReader(sha256.New()), nil
+		},
+		NewWriter: func(Options) (ResettableWriter, error) {
+			return NewChecksumWriter(sha256.New()), nil
+		},
+	})
+	RegisterFormat("base64", Format{
+		Keys: []string{"encoding"},
+		NewReader: func(opts Options) (ResettableReader, error) {
+			enc, err := base64Encoding(opts)
+			if err != nil {
+				return nil, err
+			}
+			return NewBase64Reader(enc), nil
+		},
+		NewWriter: func(opts Options) (ResettableWriter, error) {
+			enc, err := base64Encoding(opts)
+			if err != nil {
+				return nil, err
+			}
+			return NewBase64Writer(enc), nil
+		},
+	})
+	RegisterFormat("hex", Format{
+		NewReader: func(Options) (ResettableReader, error) {
+			return NewHexReader(), nil
+		},
+		NewWriter: func(Options) (ResettableWriter, error) {
+			return NewHexWriter(), nil
+		},
+	})
+}
+
+// base64Encoding returns the encoding named by the "encoding" option: std (the
+// default), url, rawstd or rawurl.
+func base64Encoding(opts Options) (*base64.Encoding, error) {
+	switch name := opts.String("encoding", "std"); name {
+	case "std":
+		return base64.StdEncoding, nil
+	case "url":
+		return base64.URLEncoding, nil
+	case "rawstd":
+		return base64.RawStdEncoding, nil
+	case "rawurl":
+		return base64.RawURLEncoding, nil
+	default:
+		return nil, fmt.Errorf("option encoding: unknown base64 encoding %q", name)
+	}
+}
@@ -0,0 +1,84 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// closeTracker counts the Close calls of the layers of the "closetrack" test format.
+var closeTracker struct {
+	readers, writers int
+}
+
+type trackedReader struct{ PassthroughReader }
+
+func (r *trackedReader) Close() error {
+	closeTracker.readers++
+	return nil
+}
+
+type trackedWriter struct{ TeeWriter }
+
+func (w *trackedWriter) Close() error {
+	closeTracker.writers++
+	return nil
+}
+
+func init() {
+	RegisterFormat("closetrack", Format{
+		NewReader: func(Options) (ResettableReader, error) { return &trackedReader{}, nil },
+		NewWriter: func(Options) (ResettableWriter, error) {
+			return &trackedWriter{TeeWriter{side: io.Discard}}, nil
+		},
+	})
+}
+
+func TestFailedChainBuildClosesLayers(t *testing.T) {
+	closeTracker.readers, closeTracker.writers = 0, 0
+
+	base := &closeCounter{}
+	if _, err := BuildWriterChain(base, "closetrack|closetrack|base64:encoding=bogus"); err == nil {
+		t.Fatal("BuildWriterChain succeeded with an invalid stage")
+	}
+	if closeTracker.writers != 2 || base.closed != 0 {
+		t.Fatalf("closed %d writers and the base %d times, want 2 and 0", closeTracker.writers, base.closed)
+	}
+
+	if _, err := BuildReaderChain(strings.NewReader(""), "closetrack|base64:encoding=bogus"); err == nil {
+		t.Fatal("BuildReaderChain succeeded with an invalid stage")
+	}
+	if closeTracker.readers != 1 {
+		t.Fatalf("closed %d readers, want 1", closeTracker.readers)
+	}
+}
+
+func TestChainFromSpecsRejectsUnknownOptions(t *testing.T) {
+	specs := []LayerSpec{{Name: "base64", Options: Options{"encodng": "url"}}}
+	if _, err := BuildWriterChainFromSpecs(io.Discard, specs); err == nil || !strings.Contains(err.Error(), `unknown option "encodng"`) {
+		t.Fatalf("BuildWriterChainFromSpecs error = %v, want unknown option", err)
+	}
+	if _, err := BuildReaderChainFromSpecs(strings.NewReader(""), specs); err == nil {
+		t.Fatal("BuildReaderChainFromSpecs accepted an unknown option")
+	}
+
+	specs[0].Options = Options{"encoding": "url"}
+	if _, err := BuildWriterChainFromSpecs(io.Discard, specs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChainSpecRejectsUnknownOptions(t *testing.T) {
+	for _, spec := range []string{"hex:foo=1", "sha256:algo=sha1", "base64:encodng=url"} {
+		closeTracker.writers = 0
+		if _, err := BuildWriterChain(io.Discard, "closetrack|"+spec); err == nil {
+			t.Errorf("BuildWriterChain(%q) accepted an unknown option", spec)
+		}
+		if closeTracker.writers != 0 {
+			t.Errorf("BuildWriterChain(%q) built layers before rejecting the spec", spec)
+		}
+		if _, err := BuildReaderChain(strings.NewReader(""), spec); err == nil {
+			t.Errorf("BuildReaderChain(%q) accepted an unknown option", spec)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package iochain
+
+import (
+	"io"
+	"time"
+)
+
+// RetryPolicy controls how RetryWriter and RetryReader retry failed calls.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first failed attempt of a call.
+	MaxRetries int
+	// Backoff returns the delay before retry number attempt, starting at 1. A nil
+	// Backoff retries immediately.
+	Backoff func(attempt int) time.Duration
+	// Retryable reports whether err is worth retrying. A nil Retryable retries every
+	// error except io.EOF. Inside a chain, errors from lower layers arrive wrapped in
+	// *LayerError, so match them with errors.Is or errors.As.
+	Retryable func(err error) bool
+}
+
+// ExponentialBackoff returns a Backoff doubling from base up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// retryable reports whether the policy retries err at all.
+func (p RetryPolicy) retryable(err error) bool {
+	if err == io.EOF || p.MaxRetries <= 0 {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// retry reports whether a call that failed with err on retry number attempt should be
+// retried, sleeping for the backoff first.
+func (p RetryPolicy) retry(attempt int, err error) bool {
+	if attempt > p.MaxRetries || !p.retryable(err) {
+		return false
+	}
+	if p.Backoff != nil {
+		if d := p.Backoff(attempt); d > 0 {
+			time.Sleep(d)
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package iochain
+
+import "io"
+
+var _ ResettableReader = (*RetryReader)(nil)
+
+// RetryReader retries failed reads from its source according to a RetryPolicy. A read
+// that returned data together with a retryable error returns the data alone; the error
+// is retried on the next call if it recurs. Other errors are returned with the data.
+// io.EOF is never retried.
+type RetryReader struct {
+	r      io.Reader
+	policy RetryPolicy
+}
+
+// NewRetryReader creates a RetryReader using policy. The source is set with Reset,
+// typically by MultiReader.AddReader.
+func NewRetryReader(policy RetryPolicy) *RetryReader {
+	return &RetryReader{policy: policy}
+}
+
+// Read reads into p, retrying failures. It returns the last error once the policy gives up.
+func (r *RetryReader) Read(p []byte) (int, error) {
+	if r.r == nil {
+		return 0, io.EOF
+	}
+
+	for attempt := 1; ; attempt++ {
+		n, err := r.r.Read(p)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			if r.policy.retryable(err) {
+				return n, nil
+			}
+			return n, err
+		}
+		if !r.policy.retry(attempt, err) {
+			return 0, err
+		}
+	}
+}
+
+// Reset changes the source.
+func (r *RetryReader) Reset(src io.Reader) error {
+	r.r = src
+	return nil
+}
@@ -0,0 +1,52 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// scriptedReader returns its results in order.
+type scriptedReader []struct {
+	data string
+	err  error
+}
+
+func (s *scriptedReader) Read(p []byte) (int, error) {
+	if len(*s) == 0 {
+		return 0, io.EOF
+	}
+	r := (*s)[0]
+	*s = (*s)[1:]
+	return copy(p, r.data), r.err
+}
+
+func TestRetryReaderKeepsPermanentErrorWithData(t *testing.T) {
+	src := &scriptedReader{{"abc", io.ErrUnexpectedEOF}, {"", io.EOF}}
+	r := NewRetryReader(RetryPolicy{
+		MaxRetries: 3,
+		Retryable:  func(err error) bool { return !errors.Is(err, io.ErrUnexpectedEOF) },
+	})
+	if err := r.Reset(src); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := io.ReadAll(r)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ReadAll error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestRetryReaderRetriesTransientError(t *testing.T) {
+	transient := errors.New("transient")
+	src := &scriptedReader{{"ab", transient}, {"", transient}, {"cd", nil}}
+	r := NewRetryReader(RetryPolicy{MaxRetries: 2})
+	if err := r.Reset(src); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil || string(got) != "abcd" {
+		t.Fatalf("ReadAll = %q, %v; want \"abcd\", nil", got, err)
+	}
+}
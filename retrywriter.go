@@ -0,0 +1,47 @@
+package iochain
+
+import "io"
+
+var _ ResettableWriter = (*RetryWriter)(nil)
+
+// RetryWriter retries failed writes to its downstream writer according to a
+// RetryPolicy. After a partial write only the unwritten suffix is retried, so no byte
+// is written twice. Short writes without an error are retried as io.ErrShortWrite.
+type RetryWriter struct {
+	w      io.Writer
+	policy RetryPolicy
+}
+
+// NewRetryWriter creates a RetryWriter using policy. The downstream writer is set with
+// Reset, typically by StackWriter.AddWriter.
+func NewRetryWriter(policy RetryPolicy) *RetryWriter {
+	return &RetryWriter{policy: policy}
+}
+
+// Write writes p, retrying failures. It returns the last error once the policy gives up.
+func (r *RetryWriter) Write(p []byte) (int, error) {
+	if r.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+
+	written := 0
+	for attempt := 1; ; attempt++ {
+		n, err := r.w.Write(p[written:])
+		written += n
+		if err == nil {
+			if written == len(p) {
+				return written, nil
+			}
+			err = io.ErrShortWrite
+		}
+		if !r.policy.retry(attempt, err) {
+			return written, err
+		}
+	}
+}
+
+// Reset changes the downstream writer.
+func (r *RetryWriter) Reset(w io.Writer) error {
+	r.w = w
+	return nil
+}
@@ -0,0 +1,98 @@
+package iochain
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var _ Rotator = (*RotatingFileWriter)(nil)
+
+// RotatingFileWriter writes to a file and rolls it over once it reaches a size or an age.
+// The full file is renamed with a timestamp suffix and a new one is created at the same
+// path. Used as the base of a StackWriter, the layers are finished and restarted on
+// every rotation; rotation happens between writes, so a file can exceed maxSize by the
+// output of one write.
+type RotatingFileWriter struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFileWriter opens path for appending, creating it if needed. A zero maxSize
+// or maxAge disables that threshold.
+func NewRotatingFileWriter(path string, maxSize int64, maxAge time.Duration) (*RotatingFileWriter, error) {
+	r := &RotatingFileWriter{path: path, maxSize: maxSize, maxAge: maxAge}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes p to the current file.
+func (r *RotatingFileWriter) Write(p []byte) (int, error) {
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// ShouldRotate reports whether the current file is non-empty and has reached maxSize or maxAge.
+func (r *RotatingFileWriter) ShouldRotate() bool {
+	if r.f == nil || r.size == 0 {
+		return false
+	}
+	return (r.maxSize > 0 && r.size >= r.maxSize) ||
+		(r.maxAge > 0 && time.Since(r.opened) >= r.maxAge)
+}
+
+// Rotate renames the current file to path.<timestamp>, closes it and creates a new file
+// at path. If the rename fails, the current file stays open and receives further writes.
+func (r *RotatingFileWriter) Rotate() error {
+	rotated := r.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	closeErr := r.Close()
+	if err := r.open(); err != nil {
+		return errors.Join(closeErr, err)
+	}
+	return closeErr
+}
+
+// Path returns the path of the current file.
+func (r *RotatingFileWriter) Path() string {
+	return r.path
+}
+
+// Close closes the current file.
+func (r *RotatingFileWriter) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func (r *RotatingFileWriter) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
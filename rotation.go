@@ -0,0 +1,94 @@
+package iochain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Rotator is implemented by base writers that roll over to a new destination, such as
+// RotatingFileWriter. A StackWriter whose base is a Rotator checks ShouldRotate before
+// writing and, when it reports true, finishes its layers, calls Rotate and Resets the
+// layers onto the new destination, so every destination holds complete streams.
+type Rotator interface {
+	ShouldRotate() bool
+	Rotate() error
+}
+
+// Rotate rolls the base writer over immediately. It fails if the base is not a Rotator.
+func (m *StackWriter) Rotate() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	rotator, ok := m.base.(Rotator)
+	if !ok {
+		return errors.New("base writer does not implement Rotator")
+	}
+	return m.rotate(rotator)
+}
+
+// maybeRotate rotates the base if it is a Rotator that asks for it. The lock must be
+// held and the stack non-empty.
+func (m *StackWriter) maybeRotate() error {
+	if rotator, ok := m.base.(Rotator); ok && rotator.ShouldRotate() {
+		return m.rotate(rotator)
+	}
+	return nil
+}
+
+// Finisher is implemented by layers whose Close does more than end the stream they
+// write, such as closing side writers or channels. Rotation calls Finish on them instead
+// of Close, and then Resets them onto the new destination.
+type Finisher interface {
+	Finish() error
+}
+
+// rotate flushes and finishes the layers above the base, rotates the base and Resets
+// the layers onto it again. Layers are finished with Finish when they implement
+// Finisher and with Close otherwise. The layers are Reset even if the base fails to
+// rotate, so the chain keeps writing complete streams to the current destination. The
+// lock must be held.
+func (m *StackWriter) rotate(rotator Rotator) error {
+	var errs []error
+	for i := len(m.writers) - 1; i >= 1; i-- {
+		if flusher, ok := m.writers[i].(Flusher); ok {
+			start := m.callStart()
+			err := flusher.Flush()
+			m.hooks.flush(i, m.writers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.writers[i], err))
+			}
+		}
+	}
+	for i := len(m.writers) - 1; i >= 1; i-- {
+		var finish func() error
+		switch w := m.writers[i].(type) {
+		case Finisher:
+			finish = w.Finish
+		case io.Closer:
+			finish = w.Close
+		default:
+			continue
+		}
+		start := m.callStart()
+		err := finish()
+		m.hooks.close(i, m.writers[i], start, err)
+		if err != nil {
+			errs = append(errs, layerErr(i, m.writers[i], err))
+		}
+	}
+
+	if err := rotator.Rotate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i := 1; i < len(m.writers); i++ {
+		if err := m.writers[i].(ResettableWriter).Reset(m.link(i - 1)); err != nil {
+			errs = append(errs, fmt.Errorf("iochain: reset failed while rotating: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
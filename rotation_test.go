@@ -0,0 +1,176 @@
+package iochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bufRotator is a Rotator collecting each destination in its own buffer.
+type bufRotator struct {
+	files []*bytes.Buffer
+	err   error
+}
+
+func (b *bufRotator) Write(p []byte) (int, error) {
+	if len(b.files) == 0 {
+		b.files = append(b.files, new(bytes.Buffer))
+	}
+	return b.files[len(b.files)-1].Write(p)
+}
+
+func (b *bufRotator) ShouldRotate() bool { return false }
+
+func (b *bufRotator) Rotate() error {
+	if b.err != nil {
+		return b.err
+	}
+	b.files = append(b.files, new(bytes.Buffer))
+	return nil
+}
+
+// closeCounter records Close calls.
+type closeCounter struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func gunzipString(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRotateFailureKeepsChainUsable(t *testing.T) {
+	base := &bufRotator{}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(AdaptWriter(gzip.NewWriter(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+
+	base.err = errors.New("rotate failed")
+	if err := sw.Rotate(); !errors.Is(err, base.err) {
+		t.Fatalf("Rotate = %v, want %v", err, base.err)
+	}
+	if _, err := sw.Write([]byte("two")); err != nil {
+		t.Fatalf("Write after failed rotation: %v", err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if len(base.files) != 1 {
+		t.Fatalf("got %d destinations, want 1", len(base.files))
+	}
+	// Both streams land in the same destination as consecutive gzip members.
+	if got := gunzipString(t, base.files[0].Bytes()); got != "onetwo" {
+		t.Fatalf("decompressed %q, want %q", got, "onetwo")
+	}
+}
+
+func TestRotateFinishesWithoutClosingSideWriters(t *testing.T) {
+	base := &bufRotator{}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	side := &closeCounter{}
+	if err := sw.AddWriter(NewTeeWriter(side)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(AdaptWriter(gzip.NewWriter(nil))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sw.Write([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if side.closed != 0 {
+		t.Fatalf("side writer closed %d times by rotation", side.closed)
+	}
+	if _, err := sw.Write([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if len(base.files) != 2 {
+		t.Fatalf("got %d destinations, want 2", len(base.files))
+	}
+	for i, want := range []string{"first", "second"} {
+		if got := gunzipString(t, base.files[i].Bytes()); got != want {
+			t.Fatalf("destination %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRotateFiresHooks(t *testing.T) {
+	base := &bufRotator{}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flushes, closes int
+	if err := sw.SetHooks(Hooks{
+		OnFlush: func(HookEvent) { flushes++ },
+		OnClose: func(HookEvent) { closes++ },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(AdaptWriter(gzip.NewWriter(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if flushes != 1 || closes != 1 {
+		t.Fatalf("rotation fired %d flush and %d close hooks, want 1 each", flushes, closes)
+	}
+}
+
+func TestRotatingFileWriterKeepsFileOnFailedRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	r, err := NewRotatingFileWriter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renaming fails once the file's directory is gone.
+	if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rotate(); err == nil {
+		t.Fatal("Rotate succeeded without a directory")
+	}
+	if _, err := r.Write([]byte("b")); err != nil {
+		t.Fatalf("Write after failed rotation: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package iochain
+
+import "io"
+
+// SpannedReader reads one logical stream stored across numbered volumes, such as
+// archive.001, archive.002 and so on. Volumes are opened on demand through a factory that
+// returns io.EOF once there are no more volumes, and each volume is closed as soon as it
+// has been read to the end.
+//
+// When pushed onto a MultiReader, Reset makes the previous layer volume 0 and the factory
+// is asked for volumes from index 1 on.
+type SpannedReader struct {
+	open  func(index int) (io.ReadCloser, error)
+	cur   io.Reader
+	owned bool // whether cur was opened by the factory and must be closed
+	next  int  // index of the next volume to open
+	done  bool
+}
+
+// NewSpannedReader creates a SpannedReader opening volumes with open, starting at index 0.
+func NewSpannedReader(open func(index int) (io.ReadCloser, error)) *SpannedReader {
+	return &SpannedReader{open: open}
+}
+
+// Read reads from the current volume and moves on to the next one at its end.
+// io.EOF is only returned after the last volume.
+func (r *SpannedReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.done {
+				return 0, io.EOF
+			}
+			if err := r.openNext(); err != nil {
+				return 0, err
+			}
+			if r.done {
+				return 0, io.EOF
+			}
+		}
+
+		n, err := r.cur.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		if err := r.closeCurrent(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Reset closes the current volume and restarts the span with src as volume 0.
+func (r *SpannedReader) Reset(src io.Reader) error {
+	err := r.closeCurrent()
+	r.cur = src
+	r.owned = false
+	r.next = 1
+	r.done = false
+	return err
+}
+
+// Close closes the current volume if it was opened by the factory.
+func (r *SpannedReader) Close() error {
+	return r.closeCurrent()
+}
+
+func (r *SpannedReader) openNext() error {
+	rc, err := r.open(r.next)
+	if err == io.EOF {
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.cur = rc
+	r.owned = true
+	r.next++
+	return nil
+}
+
+func (r *SpannedReader) closeCurrent() error {
+	cur, owned := r.cur, r.owned
+	r.cur, r.owned = nil, false
+	if closer, ok := cur.(io.Closer); ok && owned {
+		return closer.Close()
+	}
+	return nil
+}
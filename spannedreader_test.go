@@ -0,0 +1,59 @@
+package iochain
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSpannedReaderCrossesVolumes(t *testing.T) {
+	var volumes []*closeCounter
+	for _, s := range []string{"spanned ", "", "across ", "volumes"} {
+		v := &closeCounter{}
+		v.WriteString(s)
+		volumes = append(volumes, v)
+	}
+	open := func(index int) (io.ReadCloser, error) {
+		if index >= len(volumes) {
+			return nil, io.EOF
+		}
+		return volumes[index], nil
+	}
+
+	r := NewSpannedReader(open)
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || string(got) != "spanned across volumes" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+	for i, v := range volumes {
+		if v.closed != 1 {
+			t.Errorf("volume %d closed %d times, want 1", i, v.closed)
+		}
+	}
+}
+
+func TestSpannedReaderInChain(t *testing.T) {
+	second := &closeCounter{}
+	second.WriteString(" and the rest")
+	mr, err := NewReader(strings.NewReader("volume 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mr.AddReader(NewSpannedReader(func(index int) (io.ReadCloser, error) {
+		if index == 1 {
+			return second, nil
+		}
+		return nil, io.EOF
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mr)
+	if err != nil || string(got) != "volume 0 and the rest" {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+	if second.closed != 1 {
+		t.Fatalf("volume 1 closed %d times, want 1", second.closed)
+	}
+}
@@ -0,0 +1,107 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+)
+
+// SpanningWriter splits its output into volumes of at most maxVolumeBytes each. When a
+// volume is full it is closed and the next one is created through a factory; writes that
+// cross the boundary continue in the next volume. Volumes are only created once there is
+// data for them, so no empty trailing volume is produced.
+//
+// When pushed onto a StackWriter, Reset makes the previous layer volume 0 and the factory
+// is asked for volumes from index 1 on. Volume 0 is then never closed by SpanningWriter.
+type SpanningWriter struct {
+	create func(index int) (io.WriteCloser, error)
+	max    int64
+
+	cur   io.Writer
+	owned bool  // whether cur was created by the factory and must be closed
+	size  int64 // bytes written to cur
+	next  int   // index of the next volume to create
+}
+
+// NewSpanningWriter creates a SpanningWriter creating volumes with create, starting at index 0.
+func NewSpanningWriter(create func(index int) (io.WriteCloser, error), maxVolumeBytes int64) *SpanningWriter {
+	return &SpanningWriter{create: create, max: maxVolumeBytes}
+}
+
+// Write writes p, rolling over to new volumes as they fill up.
+func (s *SpanningWriter) Write(p []byte) (int, error) {
+	if s.max <= 0 {
+		return 0, errors.New("volume size must be positive")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if s.cur == nil || s.size >= s.max {
+			if err := s.roll(); err != nil {
+				return written, err
+			}
+		}
+
+		chunk := p
+		if room := s.max - s.size; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		n, err := s.cur.Write(chunk)
+		s.size += int64(n)
+		written += n
+		if err == nil && n < len(chunk) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Reset closes the current volume and restarts with w as volume 0.
+func (s *SpanningWriter) Reset(w io.Writer) error {
+	err := s.closeCurrent()
+	s.cur = w
+	s.size = 0
+	s.next = 1
+	return err
+}
+
+// Flush flushes the current volume if it implements Flusher.
+func (s *SpanningWriter) Flush() error {
+	if flusher, ok := s.cur.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Close closes the current volume if it was created by the factory.
+func (s *SpanningWriter) Close() error {
+	return s.closeCurrent()
+}
+
+// roll closes the current volume and creates the next one.
+func (s *SpanningWriter) roll() error {
+	if err := s.closeCurrent(); err != nil {
+		return err
+	}
+	w, err := s.create(s.next)
+	if err != nil {
+		return err
+	}
+	s.cur = w
+	s.owned = true
+	s.size = 0
+	s.next++
+	return nil
+}
+
+func (s *SpanningWriter) closeCurrent() error {
+	cur, owned := s.cur, s.owned
+	s.cur, s.owned = nil, false
+	if closer, ok := cur.(io.Closer); ok && owned {
+		return closer.Close()
+	}
+	return nil
+}
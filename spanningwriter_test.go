@@ -0,0 +1,64 @@
+package iochain
+
+import (
+	"io"
+	"testing"
+)
+
+func TestSpanningWriterSplitsAtVolumeSize(t *testing.T) {
+	var volumes []*closeCounter
+	create := func(index int) (io.WriteCloser, error) {
+		if index != len(volumes) {
+			t.Fatalf("created volume %d, want %d", index, len(volumes))
+		}
+		v := &closeCounter{}
+		volumes = append(volumes, v)
+		return v, nil
+	}
+
+	s := NewSpanningWriter(create, 4)
+	for _, p := range []string{"ab", "cdefghij", "kl"} {
+		if n, err := s.Write([]byte(p)); err != nil || n != len(p) {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"abcd", "efgh", "ijkl"}
+	if len(volumes) != len(want) {
+		t.Fatalf("created %d volumes, want %d", len(volumes), len(want))
+	}
+	for i, v := range volumes {
+		if v.String() != want[i] || v.closed != 1 {
+			t.Errorf("volume %d = %q closed %d times, want %q closed once", i, v.String(), v.closed, want[i])
+		}
+	}
+}
+
+func TestSpanningWriterInChain(t *testing.T) {
+	base := &closeCounter{}
+	var rest []*closeCounter
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.AddWriter(NewSpanningWriter(func(index int) (io.WriteCloser, error) {
+		v := &closeCounter{}
+		rest = append(rest, v)
+		return v, nil
+	}, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if base.String() != "01234" || len(rest) != 1 || rest[0].String() != "56789" || rest[0].closed != 1 {
+		t.Fatalf("base = %q, later volumes = %d", base.String(), len(rest))
+	}
+}
@@ -0,0 +1,174 @@
+package iochain
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var _ net.Conn = (*StackConn)(nil)
+
+// StackConn applies the same layers to both directions of a connection: writes go
+// through a StackWriter and reads through a MultiReader built from one list of Layers,
+// so a peer using the same layers can talk to it. It implements net.Conn, passing
+// addresses and deadlines through to the connection when it supports them.
+//
+// The incoming side of a layer is added on the first Read, not by NewStackConn or
+// AddLayer: layers such as gzip read a header when they are added, and two peers
+// waiting for each other's header in their constructors would deadlock.
+type StackConn struct {
+	conn io.ReadWriter
+	w    *StackWriter
+	r    *MultiReader
+
+	rmu     sync.Mutex // guards pending and rerr
+	pending []Layer    // layers not yet added to r
+	rerr    error      // error adding a pending layer, returned by every later Read
+}
+
+// NewStackConn creates a StackConn over conn with layers listed from the connection outward.
+func NewStackConn(conn io.ReadWriter, layers ...Layer) (*StackConn, error) {
+	if conn == nil {
+		return nil, errors.New("connection cannot be nil")
+	}
+	// The stacks get views of conn without Close so that it is closed once, by StackConn.
+	w, err := NewStackWriter(struct{ io.Writer }{conn})
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(struct{ io.Reader }{conn})
+	if err != nil {
+		return nil, err
+	}
+	c := &StackConn{conn: conn, w: w, r: r}
+	for _, l := range layers {
+		if err := c.AddLayer(l); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// AddLayer adds l as the outermost layer of both directions. The outgoing side is
+// added immediately, the incoming side on the next Read.
+func (c *StackConn) AddLayer(l Layer) error {
+	if l == nil {
+		return errors.New("layer cannot be nil")
+	}
+	if err := c.w.AddWriter(LayerWriter(l)); err != nil {
+		return err
+	}
+
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.pending = append(c.pending, l)
+	return nil
+}
+
+// addPending adds the incoming side of the layers added since the last call. An error
+// is kept, as the incoming stack may have consumed part of a layer's header.
+func (c *StackConn) addPending() error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.rerr != nil {
+		return c.rerr
+	}
+	for len(c.pending) > 0 {
+		if err := c.r.AddReader(LayerReader(c.pending[0])); err != nil {
+			c.rerr = err
+			return err
+		}
+		c.pending = c.pending[1:]
+	}
+	return nil
+}
+
+// Writer returns the StackWriter of the outgoing direction.
+func (c *StackConn) Writer() *StackWriter {
+	return c.w
+}
+
+// Reader returns the MultiReader of the incoming direction. It first adds the
+// incoming side of pending layers, which may block until the peer has sent data, and
+// returns the error doing so, which every later Read returns too.
+func (c *StackConn) Reader() (*MultiReader, error) {
+	if err := c.addPending(); err != nil {
+		return nil, err
+	}
+	return c.r, nil
+}
+
+// Read reads decoded data from the connection.
+func (c *StackConn) Read(p []byte) (int, error) {
+	if err := c.addPending(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Write encodes p into the connection. Layers may buffer it until Flush.
+func (c *StackConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// Flush flushes the outgoing layers.
+func (c *StackConn) Flush() error {
+	return c.w.Flush()
+}
+
+// Close flushes and closes the outgoing layers, closes the incoming layers and then
+// closes the connection if it implements io.Closer. All errors are returned, joined in
+// that order.
+func (c *StackConn) Close() error {
+	errs := []error{c.w.FlushAndClose(), c.r.Close()}
+	if closer, ok := c.conn.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// LocalAddr returns the connection's local address, or nil if it has none.
+func (c *StackConn) LocalAddr() net.Addr {
+	if a, ok := c.conn.(interface{ LocalAddr() net.Addr }); ok {
+		return a.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr returns the connection's remote address, or nil if it has none.
+func (c *StackConn) RemoteAddr() net.Addr {
+	if a, ok := c.conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return a.RemoteAddr()
+	}
+	return nil
+}
+
+// SetDeadline sets the connection's read and write deadlines. Connections without
+// deadline support get watchdog deadlines on the stacks instead.
+func (c *StackConn) SetDeadline(t time.Time) error {
+	if d, ok := c.conn.(interface{ SetDeadline(time.Time) error }); ok {
+		return d.SetDeadline(t)
+	}
+	return errors.Join(c.r.SetReadDeadline(t), c.w.SetWriteDeadline(t))
+}
+
+// SetReadDeadline sets the connection's read deadline, or a watchdog deadline on the
+// incoming stack if the connection has none.
+func (c *StackConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.conn.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return c.r.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the connection's write deadline, or a watchdog deadline on the
+// outgoing stack if the connection has none.
+func (c *StackConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return c.w.SetWriteDeadline(t)
+}
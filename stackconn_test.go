@@ -0,0 +1,74 @@
+package iochain
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var gzipLayer = LayerFuncs{
+	Writer: func(w io.Writer) (io.Writer, error) { return gzip.NewWriter(w), nil },
+	Reader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+}
+
+func TestStackConnPeersWithHeaderLayers(t *testing.T) {
+	left, right := net.Pipe()
+	done := make(chan *StackConn, 2)
+	for _, conn := range []net.Conn{left, right} {
+		go func(conn net.Conn) {
+			c, err := NewStackConn(conn, gzipLayer)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- c
+		}(conn)
+	}
+	var conns []*StackConn
+	for range 2 {
+		select {
+		case c := <-done:
+			conns = append(conns, c)
+		case <-time.After(5 * time.Second):
+			t.Fatal("NewStackConn blocked waiting for the peer")
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	go func() {
+		if _, err := conns[0].Write([]byte("hello")); err != nil {
+			t.Error(err)
+		}
+		if err := conns[0].Flush(); err != nil {
+			t.Error(err)
+		}
+	}()
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conns[1], buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("ReadFull = %q, %v", buf, err)
+	}
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func TestStackConnReportsPendingLayerError(t *testing.T) {
+	conn := readWriter{strings.NewReader("not gzip at all"), io.Discard}
+	c, err := NewStackConn(conn, gzipLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Reader(); !errors.Is(err, gzip.ErrHeader) {
+		t.Fatalf("Reader error = %v, want %v", err, gzip.ErrHeader)
+	}
+	if _, err := c.Read(make([]byte, 4)); !errors.Is(err, gzip.ErrHeader) {
+		t.Fatalf("Read error = %v, want %v", err, gzip.ErrHeader)
+	}
+}
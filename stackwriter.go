@@ -1,17 +1,39 @@
 package iochain
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 // ResettableWriter is an io.Writer that can be reset to wrap another writer.
 type ResettableWriter interface {
 	io.Writer
-	Reset(w io.Writer)
+	Reset(w io.Writer) error
 }
 
+// copyBufPool holds the 32 KiB buffers used by StackWriter.ReadFrom and
+// MultiReader.WriteTo when the top layer has no fast path.
+var copyBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
+}
+
+// ErrChainClosed is returned by the methods of a StackWriter or MultiReader called
+// after Close. It wraps io.ErrClosedPipe.
+var ErrChainClosed = fmt.Errorf("chain is closed: %w", io.ErrClosedPipe)
+
+// ErrChainFailed is returned by the writes and flushes of a StackWriter after a write
+// was abandoned by WriteContext or a write deadline while it was reaching the layers:
+// the write may complete in the background, so the stream is in an unknown state.
+// The StackWriter can still be closed.
+var ErrChainFailed = errors.New("chain failed after an abandoned write")
+
 // Flusher is implemented by writers that support flushing their internal buffer.
 type Flusher interface {
 	Flush() error
@@ -19,9 +41,26 @@ type Flusher interface {
 
 // StackWriter manages a stack of writers, each one writing to the previous.
 type StackWriter struct {
-	mu      sync.Mutex
-	base    io.Writer
-	writers []io.Writer // from base to top
+	// RetryShortWrites makes Write retry the remaining bytes when the top writer
+	// accepts only part of p without an error, instead of returning io.ErrShortWrite.
+	// Set it before the first write.
+	RetryShortWrites bool
+
+	mu        sync.Mutex
+	baseMu    sync.Mutex // guards base for readers without mu; it is only changed with both held
+	base      io.Writer
+	writers   []io.Writer      // from base to top
+	written   atomic.Int64     // bytes accepted by the top writer so far
+	stats     []*layerCounter  // input counters parallel to writers, nil unless EnableStats was called
+	hooks     *Hooks           // nil unless SetHooks was called
+	progress  *progressTracker // nil unless OnProgress was called
+	autoFlush *autoFlush       // nil unless SetAutoFlush was called
+	template  *templateLayers  // set when built by a ChainTemplate
+	measured  *countingDiscard // set when built by NewMeasuringStackWriter
+	failed    bool             // set when a write was abandoned after reaching the layers
+	closed    bool             // set by Close and FlushAndClose
+
+	writeDeadline atomic.Int64 // UnixNano of the watchdog deadline, 0 if none
 }
 
 // NewStackWriter creates a StackWriter starting with the base writer.
@@ -44,70 +83,480 @@ func (m *StackWriter) AddWriter(w ResettableWriter) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	prev := m.writers[len(m.writers)-1]
-	w.Reset(prev)
+	if m.closed {
+		return ErrChainClosed
+	}
+	if err := w.Reset(m.link(len(m.writers) - 1)); err != nil {
+		return fmt.Errorf("iochain: reset failed while adding writer: %w", err)
+	}
 
 	m.writers = append(m.writers, w)
+	if m.stats != nil {
+		m.stats = append(m.stats, new(layerCounter))
+	}
 	return nil
 }
 
-// Write writes to the top-most writer in the stack.
+// Depth returns the number of writers in the stack, counting the base.
+// It returns 0 once the stack has been closed.
+func (m *StackWriter) Depth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.writers)
+}
+
+// Base returns the current base writer: the one the stack was created with, or the
+// last one passed to ResetBase.
+func (m *StackWriter) Base() io.Writer {
+	m.baseMu.Lock()
+	defer m.baseMu.Unlock()
+	return m.base
+}
+
+// ResetBase replaces the base writer with newBase without rebuilding the stack: the
+// layers are flushed into the old base and then Reset, from the bottom up, onto the
+// new one. Whether a layer keeps its state across Reset depends on the layer; most
+// compressors start a new stream. The swap happens even if the flush fails, since the
+// old base is often broken by the time it is replaced, and the flush errors are
+// returned. The old base is not closed; it now belongs to the caller.
+func (m *StackWriter) ResetBase(newBase io.Writer) error {
+	if newBase == nil {
+		return errors.New("base writer cannot be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+
+	errs := []error{m.flushLocked()}
+	m.baseMu.Lock()
+	m.base = newBase
+	m.baseMu.Unlock()
+	m.writers[0] = newBase
+	for i := 1; i < len(m.writers); i++ {
+		if err := m.writers[i].(ResettableWriter).Reset(m.link(i - 1)); err != nil {
+			errs = append(errs, fmt.Errorf("iochain: reset failed while replacing base: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pop detaches the top-most writer, flushing and closing it if it implements Flusher and
+// io.Closer, and returns it. The writers below it stay open and receive subsequent writes.
+// The base writer cannot be popped.
+func (m *StackWriter) Pop() (io.Writer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrChainClosed
+	}
+	if len(m.writers) <= 1 {
+		return nil, errors.New("cannot pop the base writer")
+	}
+
+	top := m.writers[len(m.writers)-1]
+	m.writers = m.writers[:len(m.writers)-1]
+	if m.stats != nil {
+		m.stats = m.stats[:len(m.writers)]
+	}
+
+	var errs []error
+	if flusher, ok := top.(Flusher); ok {
+		start := m.callStart()
+		err := flusher.Flush()
+		m.hooks.flush(len(m.writers), top, start, err)
+		if err != nil {
+			errs = append(errs, layerErr(len(m.writers), top, err))
+		}
+	}
+	if closer, ok := top.(io.Closer); ok {
+		start := m.callStart()
+		err := closer.Close()
+		m.hooks.close(len(m.writers), top, start, err)
+		if err != nil {
+			errs = append(errs, layerErr(len(m.writers), top, err))
+		}
+	}
+	return top, errors.Join(errs...)
+}
+
+// PopWriter is an alias for Pop.
+func (m *StackWriter) PopWriter() (io.Writer, error) {
+	return m.Pop()
+}
+
+// RemoveAt detaches the writer at index i, counting from the base at 0, and returns it.
+// The writers from the top down to i are flushed first so no buffered data is lost, then
+// the removed writer is closed if it implements io.Closer and the writer above it is
+// Reset onto the writer below it. For most layers that Reset starts a new stream. The
+// base writer cannot be removed.
+func (m *StackWriter) RemoveAt(i int) (io.Writer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrChainClosed
+	}
+	if i == 0 {
+		return nil, errors.New("cannot remove the base writer")
+	}
+	if i < 0 || i >= len(m.writers) {
+		return nil, fmt.Errorf("writer index %d out of range", i)
+	}
+
+	for j := len(m.writers) - 1; j >= i; j-- {
+		if flusher, ok := m.writers[j].(Flusher); ok {
+			start := m.callStart()
+			err := flusher.Flush()
+			m.hooks.flush(j, m.writers[j], start, err)
+			if err != nil {
+				return nil, layerErr(j, m.writers[j], err)
+			}
+		}
+	}
+
+	removed := m.writers[i]
+	m.writers = append(m.writers[:i], m.writers[i+1:]...)
+	if m.stats != nil {
+		m.stats = append(m.stats[:i], m.stats[i+1:]...)
+	}
+
+	var errs []error
+	if closer, ok := removed.(io.Closer); ok {
+		start := m.callStart()
+		err := closer.Close()
+		m.hooks.close(i, removed, start, err)
+		if err != nil {
+			errs = append(errs, layerErr(i, removed, err))
+		}
+	}
+	if i < len(m.writers) {
+		if err := m.writers[i].(ResettableWriter).Reset(m.link(i - 1)); err != nil {
+			errs = append(errs, fmt.Errorf("iochain: reset failed while removing writer: %w", err))
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// Write writes to the top-most writer in the stack. A short write without an error is
+// reported as io.ErrShortWrite, or retried when RetryShortWrites is set. Errors from
+// the layers are wrapped in a *LayerError identifying the writer that failed; the same
+// holds for Flush and Close.
 func (m *StackWriter) Write(p []byte) (int, error) {
+	if ctx, cancel := watchdogContext(&m.writeDeadline); ctx != nil {
+		defer cancel()
+		n, err := m.WriteContext(ctx, p)
+		return n, deadlineErr(err)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.writers) == 0 {
-		return 0, io.ErrClosedPipe
+	if m.closed {
+		return 0, ErrChainClosed
 	}
-	return m.writers[len(m.writers)-1].Write(p)
+	return m.writeTop(p)
+}
+
+// WriteString writes s to the top-most writer, through its io.StringWriter
+// implementation when it has one to avoid copying s. Short writes are handled as in Write.
+func (m *StackWriter) WriteString(s string) (int, error) {
+	if ctx, cancel := watchdogContext(&m.writeDeadline); ctx != nil {
+		defer cancel()
+		n, err := m.WriteContext(ctx, []byte(s))
+		return n, deadlineErr(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrChainClosed
+	}
+	if err := m.beforeWrite(); err != nil {
+		return 0, err
+	}
+	top := m.writers[len(m.writers)-1]
+	sw, ok := top.(io.StringWriter)
+	if !ok {
+		return m.writeTop([]byte(s))
+	}
+
+	written := 0
+	for {
+		start := m.callStart()
+		n, err := sw.WriteString(s[written:])
+		m.countTop(n, start, err)
+		m.addWritten(int64(n))
+		written += n
+		if err != nil {
+			return written, layerErr(len(m.writers)-1, top, err)
+		}
+		if written == len(s) {
+			return written, m.afterWrite()
+		}
+		if !m.RetryShortWrites || n == 0 {
+			return written, layerErr(len(m.writers)-1, top, io.ErrShortWrite)
+		}
+	}
+}
+
+// writeTop writes p to the top writer. The lock must be held and the stack non-empty.
+// Retries stop with io.ErrShortWrite if the top writer makes no progress.
+func (m *StackWriter) writeTop(p []byte) (int, error) {
+	if err := m.beforeWrite(); err != nil {
+		return 0, err
+	}
+	top := m.writers[len(m.writers)-1]
+	written := 0
+	for {
+		start := m.callStart()
+		n, err := top.Write(p[written:])
+		m.countTop(n, start, err)
+		m.addWritten(int64(n))
+		written += n
+		if err != nil {
+			return written, layerErr(len(m.writers)-1, top, err)
+		}
+		if written == len(p) {
+			return written, m.afterWrite()
+		}
+		if !m.RetryShortWrites || n == 0 {
+			return written, layerErr(len(m.writers)-1, top, io.ErrShortWrite)
+		}
+	}
+}
+
+// WriteContext writes p to the top-most writer unless ctx is done first. It returns
+// ctx.Err() without writing if ctx is already done, and stops waiting for the lock or
+// the write once ctx is cancelled. A write that has already started cannot be
+// interrupted: it completes in the background on a copy of p, its result is lost and
+// the chain fails with ErrChainFailed, since an unknown part of p reached the layers.
+func (m *StackWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// state moves from pending to abandoned if the caller gives up first; otherwise to
+	// writing, and then to done, or to failed if the caller gives up during the write.
+	const (
+		pending = iota
+		abandoned
+		writing
+		done
+		failed
+	)
+	var state atomic.Int32
+
+	type result struct {
+		n   int
+		err error
+	}
+	results := make(chan result, 1)
+	buf := append([]byte(nil), p...)
+	go func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			results <- result{0, err}
+			return
+		}
+		if m.closed {
+			results <- result{0, ErrChainClosed}
+			return
+		}
+		if !state.CompareAndSwap(pending, writing) {
+			return
+		}
+		n, err := m.writeTop(buf)
+		if !state.CompareAndSwap(writing, done) {
+			m.failed = true
+		}
+		results <- result{n, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.n, r.err
+	case <-ctx.Done():
+		if state.CompareAndSwap(pending, abandoned) || state.CompareAndSwap(writing, failed) {
+			return 0, ctx.Err()
+		}
+		r := <-results
+		return r.n, r.err
+	}
+}
+
+// ReadFrom copies r into the top-most writer until io.EOF, using the top writer's
+// io.ReaderFrom implementation when it has one. It holds the lock for the whole copy.
+func (m *StackWriter) ReadFrom(r io.Reader) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrChainClosed
+	}
+	top := m.writers[len(m.writers)-1]
+
+	if rf, ok := top.(io.ReaderFrom); ok {
+		if err := m.beforeWrite(); err != nil {
+			return 0, err
+		}
+		start := m.callStart()
+		n, err := rf.ReadFrom(r)
+		m.countTop(int(n), start, err)
+		m.addWritten(n)
+		if err != nil {
+			return n, layerErr(len(m.writers)-1, top, err)
+		}
+		return n, m.afterWrite()
+	}
+
+	bufp := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(bufp)
+	buf := *bufp
+	var total int64
+	for {
+		nr, rerr := r.Read(buf)
+		if nr > 0 {
+			if err := m.beforeWrite(); err != nil {
+				return total, err
+			}
+			start := m.callStart()
+			nw, werr := top.Write(buf[:nr])
+			m.countTop(nw, start, werr)
+			total += int64(nw)
+			m.addWritten(int64(nw))
+			if werr == nil && nw < nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				return total, layerErr(len(m.writers)-1, top, werr)
+			}
+			if err := m.afterWrite(); err != nil {
+				return total, err
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+// BytesWritten returns the total number of bytes accepted by the top-most writer.
+// It is safe to call concurrently with Write.
+func (m *StackWriter) BytesWritten() int64 {
+	return m.written.Load()
 }
 
 // Flush calls Flush() on all writers from top to base if they implement Flusher.
+// All errors are returned, joined in that order.
 func (m *StackWriter) Flush() error {
+	if ctx, cancel := watchdogContext(&m.writeDeadline); ctx != nil {
+		defer cancel()
+		return deadlineErr(closeWithContext(ctx, func() error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			return m.flushLocked()
+		}))
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.flushLocked()
+}
+
+// flushLocked implements Flush. The lock must be held.
+func (m *StackWriter) flushLocked() error {
+	if m.closed {
+		return ErrChainClosed
+	}
+	if m.failed {
+		return ErrChainFailed
+	}
+	if m.autoFlush != nil {
+		m.autoFlush.flushedAt = m.written.Load()
+	}
 
-	var firstErr error
+	var errs []error
 	for i := len(m.writers) - 1; i >= 0; i-- {
 		if flusher, ok := m.writers[i].(Flusher); ok {
-			if err := flusher.Flush(); err != nil && firstErr == nil {
-				firstErr = err
+			start := m.callStart()
+			err := flusher.Flush()
+			m.hooks.flush(i, m.writers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.writers[i], err))
 			}
 		}
 	}
-	return firstErr
+	return errors.Join(errs...)
 }
 
 // Close closes all writers from top to base.
+// All errors are returned, joined in that order. Once closed, the stack rejects
+// further use with ErrChainClosed; closing it again does nothing and returns nil.
 func (m *StackWriter) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var firstErr error
+	if m.closed {
+		return nil
+	}
+	var errs []error
 	for i := len(m.writers) - 1; i >= 0; i-- {
 		if closer, ok := m.writers[i].(io.Closer); ok {
-			if err := closer.Close(); err != nil && firstErr == nil {
-				firstErr = err
+			start := m.callStart()
+			err := closer.Close()
+			m.hooks.close(i, m.writers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.writers[i], err))
 			}
 		}
 	}
 
 	m.writers = nil
-	return firstErr
+	m.closed = true
+	m.stopAutoFlush()
+	if m.progress != nil {
+		m.progress.finish(m.written.Load())
+	}
+	return errors.Join(errs...)
+}
+
+// CloseWithContext runs FlushAndClose but returns ctx.Err() if ctx is done before it
+// finishes. The flush and close then complete in the background and their errors are lost.
+func (m *StackWriter) CloseWithContext(ctx context.Context) error {
+	return closeWithContext(ctx, m.FlushAndClose)
 }
 
 // FlushAndClose flushes all writers (if supported) and then closes them.
+// All errors are returned, joined in the order they occurred. Like Close, it does
+// nothing on a closed stack.
 func (m *StackWriter) FlushAndClose() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var firstErr error
+	if m.closed {
+		return nil
+	}
+	var errs []error
 
 	// Flush from top to base
 	for i := len(m.writers) - 1; i >= 0; i-- {
 		if flusher, ok := m.writers[i].(Flusher); ok {
-			if err := flusher.Flush(); err != nil && firstErr == nil {
-				firstErr = err
+			start := m.callStart()
+			err := flusher.Flush()
+			m.hooks.flush(i, m.writers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.writers[i], err))
 			}
 		}
 	}
@@ -115,12 +564,20 @@ func (m *StackWriter) FlushAndClose() error {
 	// Close from top to base
 	for i := len(m.writers) - 1; i >= 0; i-- {
 		if closer, ok := m.writers[i].(io.Closer); ok {
-			if err := closer.Close(); err != nil && firstErr == nil {
-				firstErr = err
+			start := m.callStart()
+			err := closer.Close()
+			m.hooks.close(i, m.writers[i], start, err)
+			if err != nil {
+				errs = append(errs, layerErr(i, m.writers[i], err))
 			}
 		}
 	}
 
 	m.writers = nil
-	return firstErr
+	m.closed = true
+	m.stopAutoFlush()
+	if m.progress != nil {
+		m.progress.finish(m.written.Load())
+	}
+	return errors.Join(errs...)
 }
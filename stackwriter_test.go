@@ -0,0 +1,329 @@
+package iochain
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytesWrittenTwoLayers(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewTeeWriter(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(WrapWriter(func(w io.Writer) io.Writer { return gzip.NewWriter(w) })); err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("counted ", 1000)
+	n, err := io.Copy(sw, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.WriteString("tail"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if want := n + 4; sw.BytesWritten() != want {
+		t.Fatalf("BytesWritten = %d, want %d", sw.BytesWritten(), want)
+	}
+}
+
+// blockingWriter blocks every Write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	writes  int
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	b.writes++
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return len(p), nil
+}
+
+func TestWriteContextCancelledBeforeWrite(t *testing.T) {
+	base := newBlockingWriter()
+	defer close(base.release)
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sw.WriteContext(ctx, []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WriteContext = %v, want %v", err, context.Canceled)
+	}
+	if base.writes != 0 {
+		t.Fatalf("base written %d times after cancellation", base.writes)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatalf("Flush after a write that never started = %v", err)
+	}
+}
+
+func TestWriteContextReturnsOnCancel(t *testing.T) {
+	base := newBlockingWriter()
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := sw.WriteContext(ctx, []byte("blocked"))
+		result <- err
+	}()
+	<-base.started
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WriteContext = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WriteContext did not return after cancellation")
+	}
+
+	// The abandoned write completes in the background and leaves the chain failed.
+	close(base.release)
+	if _, err := sw.Write([]byte("next")); !errors.Is(err, ErrChainFailed) {
+		t.Fatalf("Write after an abandoned write = %v, want %v", err, ErrChainFailed)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// shortWriter accepts at most limit bytes per Write without reporting an error.
+type shortWriter struct {
+	limit int
+	bytes.Buffer
+}
+
+func (s *shortWriter) Write(p []byte) (int, error) {
+	return s.Buffer.Write(p[:min(len(p), s.limit)])
+}
+
+func TestWriteReportsShortWrite(t *testing.T) {
+	base := &shortWriter{limit: 3}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := sw.Write([]byte("abcdefgh"))
+	if n != 3 || !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("Write = %d, %v, want 3, %v", n, err, io.ErrShortWrite)
+	}
+}
+
+func TestWriteRetriesShortWrites(t *testing.T) {
+	base := &shortWriter{limit: 3}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.RetryShortWrites = true
+	n, err := sw.Write([]byte("abcdefgh"))
+	if n != 8 || err != nil {
+		t.Fatalf("Write = %d, %v, want 8, nil", n, err)
+	}
+	if base.String() != "abcdefgh" {
+		t.Fatalf("base got %q", base.String())
+	}
+
+	// A writer that stops making progress still ends the retries.
+	base.limit = 0
+	if _, err := sw.Write([]byte("x")); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("Write = %v, want %v", err, io.ErrShortWrite)
+	}
+}
+
+func TestWriteStringUsesTopStringWriter(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(AdaptWriter(bufio.NewWriterSize(nil, 1<<16))); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := sw.WriteString("small"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("WriteString allocated %v times per call, want 0", allocs)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(101 * len("small")); sw.BytesWritten() != want || int64(out.Len()) != want {
+		t.Fatalf("BytesWritten = %d, output %d bytes, want %d", sw.BytesWritten(), out.Len(), want)
+	}
+
+	if err := sw.FlushAndClose(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.WriteString("late"); err != ErrChainClosed {
+		t.Fatalf("WriteString after close = %v, want %v", err, ErrChainClosed)
+	}
+}
+
+func TestWriteStringFallsBackToWrite(t *testing.T) {
+	var out bytes.Buffer
+	sw, err := NewStackWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewHexWriter()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.WriteString("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "6869" {
+		t.Fatalf("output = %q, want %q", out.String(), "6869")
+	}
+}
+
+func TestClosedStackWriter(t *testing.T) {
+	base := &closeCounter{}
+	sw, err := NewStackWriter(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewHexWriter()); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := sw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.closed != 1 {
+		t.Fatalf("base closed %d times, want 1", base.closed)
+	}
+
+	_, writeErr := sw.Write([]byte("x"))
+	_, stringErr := sw.WriteString("x")
+	_, readFromErr := sw.ReadFrom(strings.NewReader("x"))
+	for name, err := range map[string]error{
+		"Write":       writeErr,
+		"WriteString": stringErr,
+		"ReadFrom":    readFromErr,
+		"Flush":       sw.Flush(),
+		"AddWriter":   sw.AddWriter(NewHexWriter()),
+	} {
+		if err != ErrChainClosed || !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("%s after Close = %v, want %v", name, err, ErrChainClosed)
+		}
+	}
+}
+
+func TestResetBase(t *testing.T) {
+	oldBase := &closeCounter{}
+	sw, err := NewStackWriter(oldBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(NewHexWriter()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	var newBase bytes.Buffer
+	if err := sw.ResetBase(&newBase); err != nil {
+		t.Fatal(err)
+	}
+	if oldBase.String() != "6f6c64" || oldBase.closed != 0 {
+		t.Fatalf("old base got %q, closed %d times, want %q, open", oldBase.String(), oldBase.closed, "6f6c64")
+	}
+	if sw.Base() != &newBase || sw.Depth() != 3 {
+		t.Fatalf("Base() = %p, Depth() = %d, want %p, 3", sw.Base(), sw.Depth(), &newBase)
+	}
+
+	if _, err := sw.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if newBase.String() != "6e6577" {
+		t.Fatalf("new base got %q, want %q", newBase.String(), "6e6577")
+	}
+}
+
+func TestResetBaseSwapsAfterFlushError(t *testing.T) {
+	errBroken := errors.New("broken connection")
+	sw, err := NewStackWriter(failWriter{errBroken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddWriter(bufWriterLayer{bufio.NewWriter(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+
+	var newBase bytes.Buffer
+	if err := sw.ResetBase(&newBase); !errors.Is(err, errBroken) {
+		t.Fatalf("ResetBase error = %v, want %v", err, errBroken)
+	}
+	if _, err := sw.Write([]byte("kept")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if newBase.String() != "kept" {
+		t.Fatalf("new base got %q, want %q", newBase.String(), "kept")
+	}
+}
+
+func TestResetBaseInvalid(t *testing.T) {
+	sw, err := NewStackWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.ResetBase(nil); err == nil {
+		t.Fatal("ResetBase(nil) succeeded, want error")
+	}
+	sw.Close()
+	if err := sw.ResetBase(io.Discard); err != ErrChainClosed {
+		t.Fatalf("ResetBase after Close = %v, want %v", err, ErrChainClosed)
+	}
+}
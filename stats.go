@@ -0,0 +1,146 @@
+package iochain
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// LayerStats reports the traffic through one layer of a StackWriter or MultiReader.
+type LayerStats struct {
+	BytesIn  int64         // bytes passed into the layer; 0 for the base of a MultiReader
+	BytesOut int64         // bytes the layer passed on; 0 for the base of a StackWriter
+	Calls    int64         // Write calls into a writer layer, Read calls on a reader layer
+	Elapsed  time.Duration // time spent in those calls, including the layers they reach
+}
+
+// layerCounter accumulates the calls measured on one side of a layer.
+type layerCounter struct {
+	bytes atomic.Int64
+	calls atomic.Int64
+	nanos atomic.Int64
+}
+
+func (c *layerCounter) add(n int, start time.Time) {
+	c.bytes.Add(int64(n))
+	c.calls.Add(1)
+	c.nanos.Add(int64(time.Since(start)))
+}
+
+// EnableStats starts collecting per-layer statistics, reported by Stats. It must be
+// called before any writer is added.
+func (m *StackWriter) EnableStats() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	if len(m.writers) != 1 {
+		return errors.New("stats must be enabled before writers are added")
+	}
+	if m.stats == nil {
+		m.stats = []*layerCounter{new(layerCounter)}
+	}
+	return nil
+}
+
+// Stats returns the statistics of each writer, from base to top, or nil if EnableStats
+// was not called. They remain available after Close.
+func (m *StackWriter) Stats() []LayerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stats == nil {
+		return nil
+	}
+	stats := make([]LayerStats, len(m.stats))
+	for i, c := range m.stats {
+		stats[i] = LayerStats{
+			BytesIn: c.bytes.Load(),
+			Calls:   c.calls.Load(),
+			Elapsed: time.Duration(c.nanos.Load()),
+		}
+		if i > 0 {
+			stats[i].BytesOut = stats[i-1].BytesIn
+		}
+	}
+	return stats
+}
+
+// callStart returns the start time of a measured call on a writer, or the zero time
+// when neither stats nor hooks are enabled. The lock must be held.
+func (m *StackWriter) callStart() time.Time {
+	if m.stats == nil && m.hooks == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// countTop records a call on the top writer started at start. The lock must be held.
+func (m *StackWriter) countTop(n int, start time.Time, err error) {
+	top := len(m.writers) - 1
+	if m.stats != nil {
+		m.stats[top].add(n, start)
+	}
+	m.hooks.write(top, m.writers[top], n, start, err)
+}
+
+// EnableStats starts collecting per-layer statistics, reported by Stats. It must be
+// called before any reader is added.
+func (m *MultiReader) EnableStats() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrChainClosed
+	}
+	if len(m.readers) != 1 {
+		return errors.New("stats must be enabled before readers are added")
+	}
+	if m.stats == nil {
+		m.stats = []*layerCounter{new(layerCounter)}
+	}
+	return nil
+}
+
+// Stats returns the statistics of each reader, from base to top, or nil if EnableStats
+// was not called. They remain available after Close.
+func (m *MultiReader) Stats() []LayerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stats == nil {
+		return nil
+	}
+	stats := make([]LayerStats, len(m.stats))
+	for i, c := range m.stats {
+		stats[i] = LayerStats{
+			BytesOut: c.bytes.Load(),
+			Calls:    c.calls.Load(),
+			Elapsed:  time.Duration(c.nanos.Load()),
+		}
+		if i > 0 {
+			stats[i].BytesIn = stats[i-1].BytesOut
+		}
+	}
+	return stats
+}
+
+// callStart returns the start time of a measured call on a reader, or the zero time
+// when neither stats nor hooks are enabled. The lock must be held.
+func (m *MultiReader) callStart() time.Time {
+	if m.stats == nil && m.hooks == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// countTop records a call on the top reader started at start. The lock must be held.
+func (m *MultiReader) countTop(n int, start time.Time, err error) {
+	top := len(m.readers) - 1
+	if m.stats != nil {
+		m.stats[top].add(n, start)
+	}
+	m.hooks.read(top, m.readers[top], n, start, err)
+}
@@ -0,0 +1,103 @@
+package iochain
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// StatusReader passes reads through unchanged and periodically writes a human-readable
+// progress line such as "12.3 MB at 4.5 MB/s" to a status writer.
+// If Overwrite is set, lines start with a carriage return so a terminal shows a single
+// updating line. Errors writing the status line are ignored.
+type StatusReader struct {
+	src       io.Reader
+	status    io.Writer
+	interval  time.Duration
+	Overwrite bool
+
+	read    int64
+	start   time.Time
+	last    time.Time
+	done    bool
+	lineLen int // length of the previous line, for padding overwritten lines
+}
+
+// NewStatusReader creates a StatusReader reading from r and reporting to status every interval.
+func NewStatusReader(r io.Reader, status io.Writer, interval time.Duration) *StatusReader {
+	return &StatusReader{src: r, status: status, interval: interval}
+}
+
+// Read reads from the underlying reader and reports progress if the interval has elapsed.
+// A final line is written when the underlying reader returns io.EOF.
+func (r *StatusReader) Read(p []byte) (int, error) {
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start, r.last = now, now
+	}
+
+	n, err := r.src.Read(p)
+	r.read += int64(n)
+
+	now = time.Now()
+	switch {
+	case err == io.EOF && !r.done:
+		r.done = true
+		r.report(now, true)
+	case now.Sub(r.last) >= r.interval:
+		r.last = now
+		r.report(now, false)
+	}
+	return n, err
+}
+
+// Reset changes the underlying reader and restarts the byte count, timer and status line.
+func (r *StatusReader) Reset(src io.Reader) error {
+	r.src = src
+	r.read = 0
+	r.start = time.Time{}
+	r.last = time.Time{}
+	r.done = false
+	r.lineLen = 0
+	return nil
+}
+
+func (r *StatusReader) report(now time.Time, final bool) {
+	line := formatBytes(float64(r.read))
+	if elapsed := now.Sub(r.start).Seconds(); elapsed > 0 {
+		line += " at " + formatBytes(float64(r.read)/elapsed) + "/s"
+	}
+
+	if r.Overwrite {
+		width := len(line)
+		if pad := r.lineLen - width; pad > 0 {
+			line += strings.Repeat(" ", pad)
+		}
+		r.lineLen = width
+	}
+
+	switch {
+	case r.Overwrite && final:
+		line = "\r" + line + "\n"
+	case r.Overwrite:
+		line = "\r" + line
+	default:
+		line += "\n"
+	}
+	_, _ = io.WriteString(r.status, line)
+}
+
+// formatBytes renders n using decimal units, e.g. "12.3 MB".
+func formatBytes(n float64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	suffixes := "kMGTPE"
+	i := 0
+	for n /= unit; n >= unit && i < len(suffixes)-1; n /= unit {
+		i++
+	}
+	return fmt.Sprintf("%.1f %cB", n, suffixes[i])
+}
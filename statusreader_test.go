@@ -0,0 +1,90 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestStatusReaderResetStartsNewLine(t *testing.T) {
+	var status bytes.Buffer
+	r := NewStatusReader(strings.NewReader(strings.Repeat("x", 2000)), &status, time.Hour)
+	r.Overwrite = true
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+
+	status.Reset()
+	if err := r.Reset(strings.NewReader("short")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	line := status.String()
+	if !strings.HasPrefix(line, "\r5 B") || strings.HasSuffix(line, " \n") {
+		t.Fatalf("status line after Reset = %q, want a fresh unpadded line", line)
+	}
+}
+
+func TestStatusReaderReports(t *testing.T) {
+	var status bytes.Buffer
+	data := strings.Repeat("y", 1500)
+	r := NewStatusReader(iotest.HalfReader(strings.NewReader(data)), &status, 0)
+	got, err := io.ReadAll(r)
+	if err != nil || string(got) != data {
+		t.Fatalf("ReadAll = %d bytes, %v", len(got), err)
+	}
+	lines := strings.Split(strings.TrimSuffix(status.String(), "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("status = %q, want a line per read", status.String())
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "1.5 kB at ") || !strings.HasSuffix(last, "/s") {
+		t.Fatalf("final line = %q", last)
+	}
+
+	status.Reset()
+	r = NewStatusReader(strings.NewReader(data), &status, time.Hour)
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(status.String(), "\n") != 1 {
+		t.Fatalf("status = %q, want only the final line", status.String())
+	}
+}
+
+func TestStatusReaderOverwritePadsShorterLines(t *testing.T) {
+	var status bytes.Buffer
+	r := NewStatusReader(nil, &status, 0)
+	r.Overwrite = true
+	r.start = time.Now().Add(-time.Second)
+	r.read = 123456
+	r.report(r.start.Add(time.Second), false)
+	r.read = 999
+	r.report(r.start.Add(time.Second), true)
+
+	first := "\r123.5 kB at 123.5 kB/s"
+	second := "\r999 B at 999 B/s"
+	want := first + second + strings.Repeat(" ", len(first)-len(second)) + "\n"
+	if status.String() != want {
+		t.Fatalf("status = %q, want %q", status.String(), want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[float64]string{
+		0:      "0 B",
+		999:    "999 B",
+		1000:   "1.0 kB",
+		12.3e6: "12.3 MB",
+		4.5e9:  "4.5 GB",
+		2e21:   "2000.0 EB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%v) = %q, want %q", n, got, want)
+		}
+	}
+}
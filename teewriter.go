@@ -0,0 +1,54 @@
+package iochain
+
+import "io"
+
+var (
+	_ ResettableWriter = (*TeeWriter)(nil)
+	_ Finisher         = (*TeeWriter)(nil)
+)
+
+// TeeWriter is the write-side counterpart of ReaderToWriter: it forwards every write to
+// its downstream writer and copies the bytes the downstream accepted to a side writer,
+// such as a hash or an audit log. Errors writing to the side writer are ignored.
+type TeeWriter struct {
+	w    io.Writer
+	side io.Writer
+}
+
+// NewTeeWriter creates a TeeWriter copying to side. The downstream writer is set with
+// Reset, typically by StackWriter.AddWriter.
+func NewTeeWriter(side io.Writer) *TeeWriter {
+	return &TeeWriter{side: side}
+}
+
+// Write writes p downstream and tees the accepted bytes. It returns the downstream's
+// count and error unchanged.
+func (t *TeeWriter) Write(p []byte) (int, error) {
+	if t.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := t.w.Write(p)
+	if n > 0 {
+		_, _ = t.side.Write(p[:n])
+	}
+	return n, err
+}
+
+// Reset changes the downstream writer.
+func (t *TeeWriter) Reset(w io.Writer) error {
+	t.w = w
+	return nil
+}
+
+// Finish does nothing: a rotation keeps the side writer open.
+func (t *TeeWriter) Finish() error {
+	return nil
+}
+
+// Close closes the side writer if it implements io.Closer. The downstream writer is not closed.
+func (t *TeeWriter) Close() error {
+	if closer, ok := t.side.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
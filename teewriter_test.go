@@ -0,0 +1,35 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTeeWriterCopiesAcceptedBytes(t *testing.T) {
+	side := &closeCounter{}
+	sw, err := NewStackWriter(&shortWriter{limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tee := NewTeeWriter(side)
+	if err := sw.AddWriter(tee); err != nil {
+		t.Fatal(err)
+	}
+
+	// The downstream's short count is passed through as is, and only its bytes are teed.
+	n, err := tee.Write([]byte("abcdef"))
+	if n != 3 || err != nil || side.String() != "abc" {
+		t.Fatalf("Write = %d, %v with %q teed, want 3, nil with %q", n, err, side.String(), "abc")
+	}
+	if err := tee.Close(); err != nil || side.closed != 1 {
+		t.Fatalf("Close = %v, side closed %d times", err, side.closed)
+	}
+}
+
+func TestTeeWriterWithoutDownstream(t *testing.T) {
+	var side bytes.Buffer
+	if _, err := NewTeeWriter(&side).Write([]byte("x")); err != io.ErrClosedPipe || side.Len() != 0 {
+		t.Fatalf("Write before Reset = %v with %q teed", err, side.String())
+	}
+}
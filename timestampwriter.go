@@ -0,0 +1,91 @@
+package iochain
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// TimestampWriter prefixes every line written through it with a timestamp and a space.
+// Timestamps that go backwards are counted; if Monotonic is set they are also clamped
+// to the previous timestamp so the output never goes back in time.
+type TimestampWriter struct {
+	w         io.Writer
+	layout    string
+	Monotonic bool
+	Now       func() time.Time // clock used for timestamps; time.Now if nil
+
+	atLineStart bool
+	prev        time.Time
+	regressions int
+	buf         []byte
+}
+
+// NewTimestampWriter creates a TimestampWriter writing to w. An empty layout means time.RFC3339.
+func NewTimestampWriter(w io.Writer, layout string) *TimestampWriter {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return &TimestampWriter{w: w, layout: layout, atLineStart: true}
+}
+
+// Write writes p, inserting a timestamp at the start of each line.
+// The returned count only includes bytes of p.
+func (t *TimestampWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if t.atLineStart {
+			t.buf = append(t.timestamp().AppendFormat(t.buf[:0], t.layout), ' ')
+			if _, err := t.w.Write(t.buf); err != nil {
+				return written, err
+			}
+			t.atLineStart = false
+		}
+
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+		n, err := t.w.Write(line)
+		written += n
+		if err == nil && n < len(line) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+		if line[len(line)-1] == '\n' {
+			t.atLineStart = true
+		}
+		p = p[len(line):]
+	}
+	return written, nil
+}
+
+// Reset changes the underlying writer. The next write starts a new line.
+func (t *TimestampWriter) Reset(w io.Writer) error {
+	t.w = w
+	t.atLineStart = true
+	return nil
+}
+
+// Regressions returns how many timestamps were earlier than the one before them.
+func (t *TimestampWriter) Regressions() int {
+	return t.regressions
+}
+
+func (t *TimestampWriter) timestamp() time.Time {
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	ts := now()
+	if ts.Before(t.prev) {
+		t.regressions++
+		if t.Monotonic {
+			ts = t.prev
+		}
+	}
+	t.prev = ts
+	return ts
+}
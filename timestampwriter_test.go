@@ -0,0 +1,54 @@
+package iochain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fakeClock returns the given times in order.
+func fakeClock(times ...time.Time) func() time.Time {
+	return func() time.Time {
+		t := times[0]
+		times = times[1:]
+		return t
+	}
+}
+
+func TestTimestampWriterPrefixesLinesAcrossWrites(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	w := NewTimestampWriter(&out, "15:04:05")
+	w.Now = fakeClock(base, base.Add(time.Second), base.Add(2*time.Second))
+
+	for _, p := range []string{"fir", "st\nsecond\n", "third"} {
+		if _, err := w.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "12:00:00 first\n12:00:01 second\n12:00:02 third"; out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestTimestampWriterRegressions(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, monotonic := range []bool{false, true} {
+		var out bytes.Buffer
+		w := NewTimestampWriter(&out, "")
+		w.Monotonic = monotonic
+		w.Now = fakeClock(base, base.Add(-time.Minute), base.Add(time.Minute))
+		if _, err := w.Write([]byte("a\nb\nc\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		second := "2024-05-01T11:59:00Z b\n"
+		if monotonic {
+			second = "2024-05-01T12:00:00Z b\n"
+		}
+		want := "2024-05-01T12:00:00Z a\n" + second + "2024-05-01T12:01:00Z c\n"
+		if out.String() != want || w.Regressions() != 1 {
+			t.Errorf("Monotonic=%v: output %q with %d regressions, want %q with 1", monotonic, out.String(), w.Regressions(), want)
+		}
+	}
+}
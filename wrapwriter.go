@@ -0,0 +1,49 @@
+package iochain
+
+import "io"
+
+// wrappedWriter adapts a constructor-style writer to ResettableWriter.
+type wrappedWriter struct {
+	factory func(w io.Writer) io.Writer
+	w       io.Writer
+}
+
+// WrapWriter returns a ResettableWriter whose Reset builds a fresh writer bound to the new
+// target by calling factory, so constructors such as gzip.NewWriter can be used with
+// StackWriter.AddWriter. Write, Flush and Close delegate to the writer factory produced.
+func WrapWriter(factory func(w io.Writer) io.Writer) ResettableWriter {
+	return &wrappedWriter{factory: factory}
+}
+
+func (ww *wrappedWriter) Write(p []byte) (int, error) {
+	if ww.w == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return ww.w.Write(p)
+}
+
+// Reset replaces the current writer with one built for w. The previous writer is
+// discarded without being flushed or closed.
+func (ww *wrappedWriter) Reset(w io.Writer) error {
+	ww.w = ww.factory(w)
+	return nil
+}
+
+func (ww *wrappedWriter) Flush() error {
+	if flusher, ok := ww.w.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Unwrap returns the writer built by the factory.
+func (ww *wrappedWriter) Unwrap() io.Writer {
+	return ww.w
+}
+
+func (ww *wrappedWriter) Close() error {
+	if closer, ok := ww.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}